@@ -4,38 +4,49 @@ import (
 	"fmt"
 	"github.com/alauda/helm-crds/pkg/apis/app/v1alpha1"
 	"github.com/alauda/kubectl-captain/pkg/plugin"
-	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"helm.sh/helm/pkg/chartutil"
-	"helm.sh/helm/pkg/strvals"
-	"k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
 	"k8s.io/klog"
-	"time"
 )
 
 var (
 	createExample = `
 	# create helmrequest in default ns to set it's chart version to 1.5.0 and set value 'a=b'
 	kubectl captain create foo --chart=stable/nginx-ingress -v 1.5.0 --set=a=b
+
+	# create helmrequest with values from files, latter ones win, '-' means stdin
+	kubectl captain create foo --chart=stable/nginx-ingress -f values.yaml -f values-prod.yaml --set-string=tag=1.10
 `
 )
 
 type CreateOption struct {
 	chart   string
 	version string
-	values  []string
+	values  plugin.ValueOptions
 
-	wait    bool
-	timeout int
+	waitFlags
+	// noNotes skip the chart notes printed after a successful wait
+	noNotes bool
 
+	valuesFrom plugin.ValuesFromOptions
+	// cm is the deprecated --configmap, same as --values-from=configmap:<cm>
 	cm string
 
+	// command is recorded in the revision history
+	command string
+
+	printFlags *genericclioptions.PrintFlags
+	printer    printers.ResourcePrinter
+
 	pctx *plugin.CaptainContext
 }
 
 func NewCreateOption() *CreateOption {
-	return &CreateOption{}
+	return &CreateOption{
+		printFlags: newPrintFlags("created"),
+	}
 }
 
 func NewCreateCommand() *cobra.Command {
@@ -46,6 +57,7 @@ func NewCreateCommand() *cobra.Command {
 		Short:   "create a helmrequest",
 		Example: createExample,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.command = commandLine(cmd, args)
 			if err := opts.Complete(pctx); err != nil {
 				return err
 			}
@@ -61,27 +73,31 @@ func NewCreateCommand() *cobra.Command {
 		},
 	}
 
-	cmd.Flags().StringArrayVarP(&opts.values, "set", "s", []string{}, "custom values")
+	addValueFlags(cmd, &opts.values)
 	cmd.Flags().StringVarP(&opts.version, "version", "v", "", "the chart version you want to use ")
-	cmd.Flags().BoolVarP(&opts.wait, "wait", "w", false, "wait for the helmrequest to be synced")
-	cmd.Flags().IntVarP(&opts.timeout, "timeout", "t", 0, "timeout for the wait")
+	opts.waitFlags.AddFlags(cmd, "helmrequest")
+	addNotesFlag(cmd, &opts.noNotes)
 	cmd.Flags().StringVarP(&opts.chart, "chart", "c", "", "chart name, format: <repo>/<chart>")
+	addValuesFromFlags(cmd, &opts.valuesFrom)
 	cmd.Flags().StringVarP(&opts.cm, "configmap", "", "", "configmap to obtain values from, it must contains a key called 'values.yaml'")
+	_ = cmd.Flags().MarkDeprecated("configmap", "use --values-from=configmap:<name> instead")
+	opts.printFlags.AddFlags(cmd)
 	return cmd
 }
 
-func (opts *CreateOption) Complete(pctx *plugin.CaptainContext) error {
+func (opts *CreateOption) Complete(pctx *plugin.CaptainContext) (err error) {
 	opts.pctx = pctx
-	return nil
+	opts.printer, err = opts.printFlags.ToPrinter()
+	return err
 }
 
 func (opts *CreateOption) Validate() error {
 	return nil
 }
 
-// Run do the real update
-// 1. save the old spec to annotation
-// 2. update
+// Run do the real create
+// 1. save the spec as the first revision
+// 2. create
 func (opts *CreateOption) Run(args []string) (err error) {
 	if opts.pctx == nil {
 		klog.Errorf("UpgradeOption.ctx should not be nil")
@@ -101,67 +117,40 @@ func (opts *CreateOption) Run(args []string) (err error) {
 	hr.Name = name
 	hr.Namespace = pctx.GetNamespace()
 
-	// check configmap first
 	if opts.cm != "" {
-		_, err := pctx.GetConfigMap(opts.cm)
-		if err != nil {
-			return errors.Wrap(err, "ref configmap not eixst")
-		}
-
-		optional := false
-
-		hr.Spec.ValuesFrom = []v1alpha1.ValuesFromSource{
-			{
-				ConfigMapKeyRef: &v1.ConfigMapKeySelector{
-					LocalObjectReference: v1.LocalObjectReference{Name: opts.cm},
-					Key:                  "values.yaml",
-					Optional:             &optional,
-				},
-			},
-		}
+		opts.valuesFrom.Add = append(opts.valuesFrom.Add, "configmap:"+opts.cm)
+	}
+	if err := applyValuesFrom(pctx, &hr, &opts.valuesFrom); err != nil {
+		return err
 	}
 
 	// merge values....oh,we have to import helm now....
-	base := hr.Spec.Values.AsMap()
-	for _, value := range opts.values {
-		if err := strvals.ParseInto(value, base); err != nil {
-			return errors.Wrap(err, "failed parsing --set data")
-		}
+	base, err := opts.values.MergeValues(hr.Spec.Values.AsMap(), pctx.In())
+	if err != nil {
+		return err
 	}
-
 	hr.Spec.Values = chartutil.Values(base)
 
-	_, err = pctx.CreateHelmRequest(&hr)
-	if !opts.wait {
-		if err == nil {
-			klog.Info("Create helmrequest: ", hr.GetName())
-		}
+	if err := plugin.RecordRevision(&hr, nil, pctx.GetUser(), opts.command, plugin.DefaultHistoryMax); err != nil {
 		return err
-	} else {
-		if err != nil {
-			return err
-		}
 	}
 
-	klog.Info("Start wait for helmrequest to be synced")
-
-	f := func() (done bool, err error) {
-		result, err := pctx.GetHelmRequest(hr.GetName())
-		if err != nil {
-			return false, err
-		}
-
-		if result.Status.Phase == "Failed" {
-			return false, errors.New("helmrequest failed, please check it's event to find out why")
-		}
-
-		return result.Status.Phase == "Synced", nil
+	created, err := pctx.CreateHelmRequest(&hr)
+	if err != nil {
+		return err
+	}
+	if !opts.wait {
+		return opts.printer.PrintObj(created, pctx.Out())
 	}
 
-	if opts.timeout != 0 {
-		return wait.Poll(1*time.Second, time.Duration(opts.timeout)*time.Second, f)
-	} else {
-		return wait.PollInfinite(1*time.Second, f)
+	synced, err := waitHelmRequestSynced(pctx, created, opts.waitFlags)
+	if err != nil {
+		return err
 	}
+	if err := opts.printer.PrintObj(synced, pctx.Out()); err != nil {
+		return err
+	}
+	printSyncedNotes(pctx, synced, opts.noNotes, opts.printFlags)
+	return nil
 
 }
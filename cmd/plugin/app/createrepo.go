@@ -2,36 +2,54 @@ package app
 
 import (
 	"fmt"
-	"github.com/alauda/helm-crds/pkg/apis/app/v1alpha1"
+	"github.com/alauda/helm-crds/pkg/apis/app/v1beta1"
 	"github.com/alauda/kubectl-captain/pkg/plugin"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"io/ioutil"
 	v1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
 	"k8s.io/klog"
-	"time"
 )
 
 var (
 	createRepoExample = `
 	# create a chartrepo with username and password
 	kubectl captain create-repo foo --url=www.example.org --username=tom --password=lisa
+
+	# create a chartrepo from the charts in the charts/ dir of a git repo
+	kubectl captain create-repo foo --type=git --url=https://github.com/example/charts.git --path=charts/
+
+	# create a chartrepo served with a private CA, authenticated by a client certificate
+	kubectl captain create-repo foo --url=https://charts.internal --ca-file=ca.crt --cert-file=tls.crt --key-file=tls.key
 `
 )
 
 type CreateRepoOption struct {
 	url string
+	// repoType is one of chart, git and svn
+	repoType string
+	// path is the dir of the charts in a git or svn repo
+	path string
 
 	username string
 	password string
+	tls      repoTLSFlags
+
+	waitFlags
 
-	wait    bool
-	timeout int
+	printFlags *genericclioptions.PrintFlags
+	printer    printers.ResourcePrinter
 
 	pctx *plugin.CaptainContext
 }
 
 func NewCreateRepoOption() *CreateRepoOption {
-	return &CreateRepoOption{}
+	return &CreateRepoOption{
+		printFlags: newPrintFlags("created"),
+	}
 }
 
 func NewCreateRepoCommand() *cobra.Command {
@@ -57,26 +75,39 @@ func NewCreateRepoCommand() *cobra.Command {
 		},
 	}
 
-	cmd.Flags().BoolVarP(&opts.wait, "wait", "w", false, "wait for the helmrequest to be synced")
-	cmd.Flags().IntVarP(&opts.timeout, "timeout", "t", 0, "timeout for the wait")
-	cmd.Flags().StringVarP(&opts.url, "url", "", "", "repo url")
+	opts.waitFlags.AddFlags(cmd, "chartrepo")
+	cmd.Flags().StringVarP(&opts.url, "url", "", "", "repo url, it's the vcs url for git and svn repos")
+	cmd.Flags().StringVar(&opts.repoType, "type", "chart", "repo type, one of: chart, git, svn. "+
+		"Git repos are synced from their default branch, a branch can't be chosen")
+	cmd.Flags().StringVar(&opts.path, "path", "", "the dir of the charts in a git or svn repo, default to the root")
 	cmd.Flags().StringVarP(&opts.username, "username", "u", "", "repo username")
 	cmd.Flags().StringVarP(&opts.password, "password", "p", "", "repo password")
+	opts.tls.AddFlags(cmd)
+	opts.printFlags.AddFlags(cmd)
 	return cmd
 }
 
-func (opts *CreateRepoOption) Complete(pctx *plugin.CaptainContext) error {
+func (opts *CreateRepoOption) Complete(pctx *plugin.CaptainContext) (err error) {
 	opts.pctx = pctx
-	return nil
+	opts.printer, err = opts.printFlags.ToPrinter()
+	return err
 }
 
 func (opts *CreateRepoOption) Validate() error {
-	return nil
+	if opts.url == "" {
+		return fmt.Errorf("--url is required")
+	}
+	repoType, err := plugin.ParseRepoType(opts.repoType)
+	if err != nil {
+		return err
+	}
+	if opts.path != "" && repoType == v1beta1.ChartRepoChart {
+		return fmt.Errorf("--path can only be used with git and svn repos")
+	}
+	return opts.tls.Validate()
 }
 
-// Run do the real update
-// 1. save the old spec to annotation
-// 2. update
+// Run create the secret holding the credentials if any, then the chartrepo
 func (opts *CreateRepoOption) Run(args []string) (err error) {
 	if opts.pctx == nil {
 		klog.Errorf("UpgradeOption.ctx should not be nil")
@@ -89,51 +120,88 @@ func (opts *CreateRepoOption) Run(args []string) (err error) {
 
 	name := args[0]
 	pctx := opts.pctx
-	var cr v1alpha1.ChartRepo
-	cr.Spec.URL = opts.url
-	cr.Namespace = pctx.GetNamespace()
-	cr.Name = name
+	repoType, _ := plugin.ParseRepoType(opts.repoType)
+	cr := plugin.NewChartRepo(name, pctx.GetNamespace(), repoType, opts.url, opts.path)
 
-	if opts.username != "" && opts.password != "" {
+	creds := plugin.RepoCredentials{Username: opts.username, Password: opts.password}
+	if err := opts.tls.apply(&creds); err != nil {
+		return err
+	}
+
+	if !creds.IsEmpty() {
 		cr.Spec.Secret = &v1.SecretReference{
 			Name:      name,
 			Namespace: pctx.GetNamespace(),
 		}
+	}
 
-		importOptions := ImportOptions{
-			repoNamespace: pctx.GetNamespace(),
-			pctx:          pctx,
-		}
-
-		if err := importOptions.createRepoSecret(opts.username, opts.password, name); err != nil {
-			return err
-		}
+	created, err := pctx.CreateChartRepoWithSecret(cr, creds)
+	if err != nil {
+		return err
 	}
 
-	_, err = pctx.CreateChartRepo(&cr)
 	if !opts.wait {
-		return err
+		return opts.printer.PrintObj(created, pctx.Out())
 	}
 
+	synced, err := waitChartRepoSynced(pctx, name, pctx.GetNamespace(), created.ResourceVersion, opts.waitFlags)
 	if err != nil {
-		klog.Error("Create chartrepo error: ", err)
 		return err
 	}
+	return opts.printer.PrintObj(synced, pctx.Out())
 
-	klog.Info("Start wait for chartrepo to be synced")
+}
 
-	f := func() (done bool, err error) {
-		result, err := pctx.GetChartRepo(name, pctx.GetNamespace())
-		if err != nil {
-			return false, err
-		}
-		return result.Status.Phase == "Synced", nil
+// repoTLSFlags are the tls flags of the commands writing a chartrepo secret
+type repoTLSFlags struct {
+	caFile                string
+	certFile              string
+	keyFile               string
+	insecureSkipTLSVerify bool
+
+	flags *pflag.FlagSet
+}
+
+func (f *repoTLSFlags) AddFlags(cmd *cobra.Command) {
+	f.flags = cmd.Flags()
+	cmd.Flags().StringVar(&f.caFile, "ca-file", "", "verify the repo's certificate with this CA bundle, stored as ca.crt in the secret")
+	cmd.Flags().StringVar(&f.certFile, "cert-file", "", "identify to the repo with this client certificate, stored as tls.crt in the secret")
+	cmd.Flags().StringVar(&f.keyFile, "key-file", "", "the key of the client certificate, stored as tls.key in the secret")
+	cmd.Flags().BoolVar(&f.insecureSkipTLSVerify, "insecure-skip-tls-verify", false, "skip verifying the repo's certificate, stored as "+
+		"insecureSkipTLSVerify in the secret")
+}
+
+func (f *repoTLSFlags) Validate() error {
+	if (f.certFile == "") != (f.keyFile == "") {
+		return fmt.Errorf("--cert-file and --key-file should be used together")
 	}
+	return nil
+}
 
-	if opts.timeout != 0 {
-		return wait.Poll(1*time.Second, time.Duration(opts.timeout)*time.Second, f)
-	} else {
-		return wait.PollInfinite(1*time.Second, f)
+// apply read the files to creds, insecureSkipTLSVerify is only changed if the flag is given
+func (f *repoTLSFlags) apply(creds *plugin.RepoCredentials) error {
+	if err := readTLSFiles(creds, f.caFile, f.certFile, f.keyFile); err != nil {
+		return err
 	}
+	if f.flags != nil && f.flags.Changed("insecure-skip-tls-verify") {
+		insecure := f.insecureSkipTLSVerify
+		creds.InsecureSkipTLSVerify = &insecure
+	}
+	return nil
+}
 
+// readTLSFiles read the CA, client certificate and key files to creds, empty paths are skipped
+func readTLSFiles(creds *plugin.RepoCredentials, caFile, certFile, keyFile string) (err error) {
+	read := func(path string, data *[]byte) {
+		if path == "" || err != nil {
+			return
+		}
+		if *data, err = ioutil.ReadFile(path); err != nil {
+			err = errors.Wrapf(err, "read %s error", path)
+		}
+	}
+	read(caFile, &creds.CAData)
+	read(certFile, &creds.CertData)
+	read(keyFile, &creds.KeyData)
+	return err
 }
@@ -0,0 +1,191 @@
+package app
+
+import (
+	"fmt"
+	"github.com/alauda/helm-crds/pkg/apis/app/v1alpha1"
+	"github.com/alauda/kubectl-captain/pkg/plugin"
+	"github.com/spf13/cobra"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
+	"k8s.io/klog"
+)
+
+var (
+	deleteExample = `
+	# delete helmrequest foo in default ns and wait until captain uninstalled it
+	kubectl captain delete foo -n default -w --timeout=300
+
+	# delete all the helmrequests labeled with app=nginx
+	kubectl captain delete -n default -l app=nginx
+
+	# delete helmrequest foo and only wait for the helmrequest to be gone, not it's Release resources
+	kubectl captain delete foo -n default -w --skip-release-wait
+`
+)
+
+type DeleteOption struct {
+	selector string
+
+	waitFlags
+
+	// skipReleaseWait skip waiting for the Release resources to be gone, they are never deleted by the plugin
+	skipReleaseWait bool
+
+	printFlags *genericclioptions.PrintFlags
+	printer    printers.ResourcePrinter
+
+	pctx *plugin.CaptainContext
+}
+
+func NewDeleteOption() *DeleteOption {
+	return &DeleteOption{
+		printFlags: newPrintFlags("deleted"),
+	}
+}
+
+func NewDeleteCommand() *cobra.Command {
+	opts := NewDeleteOption()
+
+	cmd := &cobra.Command{
+		Use:     "delete",
+		Short:   "delete helmrequests, captain will uninstall their releases",
+		Example: deleteExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := opts.Complete(pctx); err != nil {
+				return err
+			}
+
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+
+			if err := opts.Run(args); err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.selector, "selector", "l", "", "label selector to filter helmrequests, supports '=', '==', and '!='")
+	opts.waitFlags.AddFlags(cmd, "helmrequest")
+	cmd.Flags().Lookup("wait").Usage = "wait for captain to uninstall the helmrequest, and the helmrequest and it's Release resources to be gone"
+	cmd.Flags().BoolVar(&opts.skipReleaseWait, "skip-release-wait", false, "with --wait, only wait for the helmrequest to be gone, "+
+		"the Release resources captain keeps are left as they are and not waited for")
+	opts.printFlags.AddFlags(cmd)
+	return cmd
+}
+
+func (opts *DeleteOption) Complete(pctx *plugin.CaptainContext) (err error) {
+	opts.pctx = pctx
+	opts.printer, err = opts.printFlags.ToPrinter()
+	return err
+}
+
+func (opts *DeleteOption) Validate() error {
+	if opts.skipReleaseWait && !opts.wait {
+		return fmt.Errorf("--skip-release-wait can only be used with --wait")
+	}
+	return nil
+}
+
+// Run delete the target helmrequests, and wait for captain to uninstall them if asked
+func (opts *DeleteOption) Run(args []string) (err error) {
+	if opts.pctx == nil {
+		klog.Errorf("DeleteOption.ctx should not be nil")
+		return fmt.Errorf("DeleteOption.ctx should not be nil")
+	}
+
+	if len(args) == 0 && opts.selector == "" {
+		return fmt.Errorf("user should input helmrequest names or a label selector to delete")
+	}
+	if len(args) > 0 && opts.selector != "" {
+		return fmt.Errorf("helmrequest names and a label selector cannot be used together")
+	}
+
+	pctx := opts.pctx
+
+	var targets []*v1alpha1.HelmRequest
+	var errs []error
+	if opts.selector != "" {
+		list, err := pctx.ListHelmRequests(pctx.GetNamespace(), opts.selector)
+		if err != nil {
+			return err
+		}
+		if len(list.Items) == 0 {
+			return fmt.Errorf("no helmrequest matches selector %s", opts.selector)
+		}
+		for i := range list.Items {
+			targets = append(targets, &list.Items[i])
+		}
+	} else {
+		for _, name := range args {
+			hr, err := pctx.GetHelmRequest(name)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			targets = append(targets, hr)
+		}
+	}
+
+	var deleted []*v1alpha1.HelmRequest
+	for _, hr := range targets {
+		if err := pctx.DeleteHelmRequest(hr.Name); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		pctx.CreateEvent("Normal", "Deleting", fmt.Sprintf("Delete helmrequest %s", hr.Name), hr)
+		// the wait tells a stale phase by the version right after the delete
+		if current, err := pctx.GetHelmRequest(hr.Name); err == nil {
+			hr = current
+		}
+		deleted = append(deleted, hr)
+	}
+
+	if !opts.wait {
+		for _, hr := range deleted {
+			if err := opts.printer.PrintObj(hr, pctx.Out()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return utilerrors.NewAggregate(errs)
+	}
+
+	flags := opts.waitFlags.withDeadline()
+	for _, hr := range deleted {
+		if err := opts.waitUninstalled(hr, flags); err != nil {
+			message := fmt.Sprintf("Delete helmrequest %s error: %s", hr.Name, err.Error())
+			pctx.CreateEvent("Warning", "FailedDelete", message, hr)
+			errs = append(errs, err)
+			continue
+		}
+
+		message := fmt.Sprintf("Deleted helmrequest %s, it's release is uninstalled", hr.Name)
+		pctx.CreateEvent("Normal", "Deleted", message, hr)
+		if err := opts.printer.PrintObj(hr, pctx.Out()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// waitUninstalled wait for the helmrequest to be gone, then for captain to remove it's Release resources
+// unless --skip-release-wait is set
+func (opts *DeleteOption) waitUninstalled(hr *v1alpha1.HelmRequest, flags waitFlags) error {
+	pctx := opts.pctx
+	if err := waitHelmRequestDeleted(pctx, hr, flags); err != nil {
+		return err
+	}
+	if opts.skipReleaseWait {
+		return nil
+	}
+
+	name, ns := plugin.ReleaseName(hr)
+	pctx.Infof("Start wait for the releases of helmrequest %s to be gone", hr.Name)
+	if err := pctx.WaitReleasesDeleted(name, ns, flags.options("")); err != nil {
+		return fmt.Errorf("releases of helmrequest %s: %s", hr.Name, err.Error())
+	}
+	return nil
+}
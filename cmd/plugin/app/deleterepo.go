@@ -0,0 +1,198 @@
+package app
+
+import (
+	"fmt"
+	"github.com/alauda/helm-crds/pkg/apis/app/v1alpha1"
+	"github.com/alauda/helm-crds/pkg/apis/app/v1beta1"
+	"github.com/alauda/kubectl-captain/pkg/plugin"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"io"
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
+	"k8s.io/klog"
+	"text/tabwriter"
+)
+
+var (
+	deleteRepoExample = `
+	# delete chartrepo foo if no helmrequest uses it's charts
+	kubectl captain delete-repo foo -n alauda-system
+
+	# delete chartrepo foo even it's still in use, and the secret holding it's credentials if it's
+	# created by kubectl-captain
+	kubectl captain delete-repo foo -n alauda-system --force --delete-secret
+`
+)
+
+type DeleteRepoOption struct {
+	// force delete the chartrepo even helmrequests still use it
+	force bool
+	// deleteSecret also delete the secret referenced by the chartrepo, if it's created by this plugin
+	deleteSecret bool
+
+	printFlags *genericclioptions.PrintFlags
+	printer    printers.ResourcePrinter
+
+	pctx *plugin.CaptainContext
+}
+
+func NewDeleteRepoOption() *DeleteRepoOption {
+	return &DeleteRepoOption{
+		printFlags: newPrintFlags("deleted"),
+	}
+}
+
+func NewDeleteRepoCommand() *cobra.Command {
+	opts := NewDeleteRepoOption()
+
+	cmd := &cobra.Command{
+		Use:     "delete-repo",
+		Short:   "delete a chartrepo which is not used by any helmrequest",
+		Example: deleteRepoExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := opts.Complete(pctx); err != nil {
+				return err
+			}
+
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+
+			if err := opts.Run(args); err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.force, "force", false, "delete the chartrepo even it's still used by helmrequests")
+	cmd.Flags().BoolVar(&opts.deleteSecret, "delete-secret", false, "also delete the secret holding the chartrepo's credentials, only secrets created by kubectl-captain are deleted")
+	opts.printFlags.AddFlags(cmd)
+	return cmd
+}
+
+func (opts *DeleteRepoOption) Complete(pctx *plugin.CaptainContext) (err error) {
+	opts.pctx = pctx
+	opts.printer, err = opts.printFlags.ToPrinter()
+	return err
+}
+
+func (opts *DeleteRepoOption) Validate() error {
+	return nil
+}
+
+// Run check no helmrequest in any namespace uses the chartrepo, then delete it and optionally it's secret
+func (opts *DeleteRepoOption) Run(args []string) (err error) {
+	if opts.pctx == nil {
+		klog.Errorf("DeleteRepoOption.ctx should not be nil")
+		return fmt.Errorf("DeleteRepoOption.ctx should not be nil")
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("user should input chartrepo name to delete")
+	}
+
+	name := args[0]
+	pctx := opts.pctx
+	namespace := pctx.GetNamespace()
+
+	cr, err := pctx.GetChartRepo(name, namespace)
+	if err != nil {
+		return errors.Wrap(err, "get chartrepo error")
+	}
+
+	// check the secret before anything is deleted
+	var secret *v1.Secret
+	if opts.deleteSecret {
+		if secret, err = opts.repoSecretToDelete(cr); err != nil {
+			return err
+		}
+	}
+
+	dependants, err := pctx.RepoDependants(name)
+	if err != nil {
+		return errors.Wrap(err, "list helmrequests error")
+	}
+	if len(dependants) > 0 {
+		if !opts.force {
+			fmt.Fprintf(pctx.ErrOut(), "chartrepo %s is used by %d helmrequests:\n", name, len(dependants))
+			printDependants(pctx.ErrOut(), dependants)
+			return fmt.Errorf("chartrepo %s is still in use, their upgrades will fail after it's deleted, use --force to delete it anyway", name)
+		}
+		pctx.Warningf("chartrepo %s is still used by %d helmrequests, their upgrades will fail:", name, len(dependants))
+		printDependants(pctx.ErrOut(), dependants)
+	}
+
+	if err := pctx.DeleteChartRepo(name, namespace); err != nil {
+		return errors.Wrap(err, "delete chartrepo error")
+	}
+
+	if secret != nil {
+		if err := pctx.DeleteSecret(secret.Name, secret.Namespace); err != nil {
+			return errors.Wrap(err, "delete chartrepo secret error")
+		}
+		pctx.Infof("Deleted secret %s/%s", secret.Namespace, secret.Name)
+	}
+
+	return opts.printer.PrintObj(cr, pctx.Out())
+}
+
+// repoSecretToDelete returns the secret of the chartrepo to delete along with it, it's nil if other chartrepos
+// still reference it. Secrets not created by this plugin are refused.
+func (opts *DeleteRepoOption) repoSecretToDelete(cr *v1beta1.ChartRepo) (*v1.Secret, error) {
+	pctx := opts.pctx
+	ref := cr.Spec.Secret
+	if ref == nil {
+		pctx.Infof("Chartrepo %s has no secret", cr.Name)
+		return nil, nil
+	}
+	secretNamespace := ref.Namespace
+	if secretNamespace == "" {
+		secretNamespace = cr.Namespace
+	}
+
+	repos, err := pctx.ListChartRepos("")
+	if err != nil {
+		return nil, errors.Wrap(err, "list chartrepos error")
+	}
+	for _, other := range repos.Items {
+		if other.Spec.Secret == nil || other.Spec.Secret.Name != ref.Name {
+			continue
+		}
+		otherNamespace := other.Spec.Secret.Namespace
+		if otherNamespace == "" {
+			otherNamespace = other.Namespace
+		}
+		if otherNamespace == secretNamespace && !(other.Name == cr.Name && other.Namespace == cr.Namespace) {
+			pctx.Warningf("secret %s/%s is also used by chartrepo %s/%s, keep it", secretNamespace, ref.Name, other.Namespace, other.Name)
+			return nil, nil
+		}
+	}
+
+	secret, err := pctx.GetRepoSecret(cr)
+	if apierrors.IsNotFound(err) {
+		pctx.Infof("Secret %s/%s of chartrepo %s not found", secretNamespace, ref.Name, cr.Name)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "get chartrepo secret error")
+	}
+	if !plugin.IsPluginRepoSecret(secret) {
+		return nil, fmt.Errorf("secret %s/%s is not created by kubectl-captain, refuse to delete it, "+
+			"drop --delete-secret and delete it by kubectl if it's no longer needed", secretNamespace, ref.Name)
+	}
+	return secret, nil
+}
+
+// printDependants print the helmrequests using a chartrepo as a table
+func printDependants(out io.Writer, items []v1alpha1.HelmRequest) {
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "  NAMESPACE\tNAME\tCHART\tVERSION")
+	for _, hr := range items {
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", hr.Namespace, hr.Name, hr.Spec.Chart, orNone(hr.Spec.Version))
+	}
+	_ = w.Flush()
+}
@@ -0,0 +1,185 @@
+package app
+
+import (
+	"fmt"
+	"github.com/alauda/helm-crds/pkg/apis/app/v1beta1"
+	"github.com/alauda/kubectl-captain/pkg/plugin"
+	"github.com/spf13/cobra"
+	"io"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
+	"k8s.io/klog"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+var (
+	describeRepoExample = `
+	# show chartrepo stable with it's secret, charts and events
+	kubectl captain describe-repo stable -n alauda-system
+`
+)
+
+type DescribeRepoOption struct {
+	printFlags *genericclioptions.PrintFlags
+	printer    printers.ResourcePrinter
+
+	pctx *plugin.CaptainContext
+}
+
+func NewDescribeRepoOption() *DescribeRepoOption {
+	return &DescribeRepoOption{
+		printFlags: newPrintFlags(""),
+	}
+}
+
+func NewDescribeRepoCommand() *cobra.Command {
+	opts := NewDescribeRepoOption()
+
+	cmd := &cobra.Command{
+		Use:     "describe-repo",
+		Short:   "show the status of a chartrepo, it's secret, charts and events",
+		Example: describeRepoExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := opts.Complete(pctx); err != nil {
+				return err
+			}
+
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+
+			if err := opts.Run(args); err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+
+	opts.printFlags.AddFlags(cmd)
+	return cmd
+}
+
+func (opts *DescribeRepoOption) Complete(pctx *plugin.CaptainContext) (err error) {
+	opts.pctx = pctx
+	opts.printer, err = opts.printFlags.ToPrinter()
+	return err
+}
+
+func (opts *DescribeRepoOption) Validate() error {
+	return nil
+}
+
+// Run print the chartrepo, the keys of it's secret, the charts it provides and events
+func (opts *DescribeRepoOption) Run(args []string) (err error) {
+	if opts.pctx == nil {
+		klog.Errorf("DescribeRepoOption.ctx should not be nil")
+		return fmt.Errorf("DescribeRepoOption.ctx should not be nil")
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("user should input a chartrepo name to describe")
+	}
+
+	pctx := opts.pctx
+	cr, err := pctx.GetChartRepo(args[0], pctx.GetNamespace())
+	if err != nil {
+		return err
+	}
+
+	if outputSpecified(opts.printFlags) {
+		return opts.printer.PrintObj(cr, pctx.Out())
+	}
+
+	events, err := pctx.GetEvents(cr)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(pctx.Out(), 0, 8, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintf(w, "Name:\t%s\n", cr.Name)
+	fmt.Fprintf(w, "Namespace:\t%s\n", cr.Namespace)
+	fmt.Fprintf(w, "Type:\t%s\n", repoType(cr))
+	fmt.Fprintf(w, "URL:\t%s\n", orNone(cr.Spec.URL))
+	if source := cr.Spec.Source; source != nil {
+		fmt.Fprintf(w, "Source URL:\t%s\n", orNone(source.URL))
+		fmt.Fprintf(w, "Source Path:\t%s\n", orNone(source.Path))
+	}
+	fmt.Fprintf(w, "Phase:\t%s\n", orNone(string(cr.Status.Phase)))
+	fmt.Fprintf(w, "Reason:\t%s\n", orNone(cr.Status.Reason))
+	fmt.Fprintf(w, "Last Sync:\t%s\n", lastSync(events))
+
+	fmt.Fprintln(w, "Secret:")
+	printRepoSecret(w, pctx, cr)
+
+	fmt.Fprintln(w, "Charts:")
+	charts, err := pctx.ListCharts(cr.Name, cr.Namespace)
+	if err != nil {
+		fmt.Fprintf(w, "  <none>\t(%s)\n", err.Error())
+	} else {
+		printCharts(w, charts.Items)
+	}
+
+	fmt.Fprintln(w, "Events:")
+	printEvents(w, events)
+
+	return nil
+}
+
+// printRepoSecret print the keys of the secret and the size of their values, the data is never printed
+func printRepoSecret(w io.Writer, pctx *plugin.CaptainContext, cr *v1beta1.ChartRepo) {
+	if cr.Spec.Secret == nil {
+		fmt.Fprintln(w, "  <none>")
+		return
+	}
+
+	secret, err := pctx.GetRepoSecret(cr)
+	if err != nil {
+		fmt.Fprintf(w, "  Name:\t%s\n", cr.Spec.Secret.Name)
+		fmt.Fprintf(w, "  Error:\t%s\n", err.Error())
+		return
+	}
+
+	fmt.Fprintf(w, "  Name:\t%s\n", secret.Name)
+	fmt.Fprintf(w, "  Namespace:\t%s\n", secret.Namespace)
+	var keys []string
+	for key := range secret.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(w, "  %s:\t%d bytes\n", key, len(secret.Data[key]))
+	}
+}
+
+// printCharts print the charts and their versions, the versions are in the order of the repo index, which
+// is newest first
+func printCharts(w io.Writer, charts []v1beta1.Chart) {
+	if len(charts) == 0 {
+		fmt.Fprintln(w, "  <none>")
+		return
+	}
+
+	sort.SliceStable(charts, func(i, j int) bool {
+		return charts[i].Name < charts[j].Name
+	})
+
+	fmt.Fprintln(w, "  NAME\tLATEST\tAPP VERSION\tVERSIONS")
+	for _, chart := range charts {
+		name, latest, appVersion := chart.Name, "<none>", "<none>"
+		var versions []string
+		for _, v := range chart.Spec.Versions {
+			if v == nil || v.Metadata == nil {
+				continue
+			}
+			if len(versions) == 0 {
+				name, latest, appVersion = v.Name, v.Version, orNone(v.AppVersion)
+			}
+			versions = append(versions, v.Version)
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", name, latest, appVersion, orNone(strings.Join(versions, ",")))
+	}
+}
@@ -0,0 +1,185 @@
+package app
+
+import (
+	"fmt"
+	"github.com/alauda/helm-crds/pkg/apis/app/v1alpha1"
+	"github.com/alauda/kubectl-captain/pkg/plugin"
+	"github.com/spf13/cobra"
+	"k8s.io/klog"
+)
+
+var (
+	diffExample = `
+	# show what upgrading helmrequest foo to chart version 1.5.0 with value 'a=b' would change
+	kubectl captain diff foo -n default -v 1.5.0 --set=a=b
+
+	# also diff the manifest, rendered by the chart of the deployed release
+	kubectl captain diff foo -n default -f values.yaml --manifest
+`
+)
+
+type DiffOption struct {
+	// upgrade computes the desired spec, it takes the same flags as the upgrade command
+	upgrade *UpgradeOption
+	diff    diffPrinter
+
+	pctx *plugin.CaptainContext
+}
+
+func NewDiffOption() *DiffOption {
+	return &DiffOption{
+		upgrade: NewUpdateOption(),
+	}
+}
+
+func NewDiffCommand() *cobra.Command {
+	opts := NewDiffOption()
+
+	cmd := &cobra.Command{
+		Use:     "diff",
+		Short:   "show what an upgrade of a helmrequest would change",
+		Example: diffExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := opts.Complete(pctx); err != nil {
+				return err
+			}
+
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+
+			if err := opts.Run(args); err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+
+	opts.upgrade.addSpecFlags(cmd)
+	cmd.Flags().BoolVar(&opts.diff.manifest, "manifest", false, "also diff the manifest rendered by the chart of the deployed release")
+	return cmd
+}
+
+func (opts *DiffOption) Complete(pctx *plugin.CaptainContext) error {
+	opts.pctx = pctx
+	opts.upgrade.pctx = pctx
+	return nil
+}
+
+func (opts *DiffOption) Validate() error {
+	return opts.upgrade.Validate()
+}
+
+// Run print the diff between the current spec and the one upgrade would write
+func (opts *DiffOption) Run(args []string) (err error) {
+	if opts.pctx == nil {
+		klog.Errorf("DiffOption.ctx should not be nil")
+		return fmt.Errorf("DiffOption.ctx should not be nil")
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("user should input helmrequest name to diff")
+	}
+
+	pctx := opts.pctx
+	hr, err := pctx.GetHelmRequest(args[0])
+	if err != nil {
+		return err
+	}
+
+	current := hr.Spec.DeepCopy()
+	if err := opts.upgrade.applySpec(hr); err != nil {
+		return err
+	}
+	return opts.diff.print(pctx, current, hr)
+}
+
+// diffPrinter print the changes of a helmrequest spec, shared by diff and upgrade --dry-run
+type diffPrinter struct {
+	// manifest also diff the manifest
+	manifest bool
+}
+
+// print the unified diff of the spec fields, the merged values and optionally the manifest,
+// nothing is printed to stdout if there is no change
+func (d *diffPrinter) print(pctx *plugin.CaptainContext, current *v1alpha1.HelmRequestSpec, desired *v1alpha1.HelmRequest) error {
+	var diffs []string
+
+	// values are diffed after merged with ValuesFrom, so leave them out here
+	currentSpec, desiredSpec := current.DeepCopy(), desired.Spec.DeepCopy()
+	currentSpec.HelmValues, desiredSpec.HelmValues = v1alpha1.HelmValues{}, v1alpha1.HelmValues{}
+	specDiff, err := plugin.DiffYAML("current/spec.yaml", "desired/spec.yaml", currentSpec, desiredSpec)
+	if err != nil {
+		return err
+	}
+	diffs = append(diffs, specDiff)
+
+	// values from secrets are masked in the printed diff, only the manifest is rendered with them
+	currentValues, err := pctx.ResolveValuesRedacted(current)
+	if err != nil {
+		return err
+	}
+	desiredValues, err := pctx.ResolveValuesRedacted(&desired.Spec)
+	if err != nil {
+		return err
+	}
+	valuesDiff, err := plugin.DiffYAML("current/values.yaml", "desired/values.yaml", currentValues, desiredValues)
+	if err != nil {
+		return err
+	}
+	diffs = append(diffs, valuesDiff)
+
+	if d.manifest {
+		values, err := pctx.ResolveValues(&desired.Spec)
+		if err != nil {
+			return err
+		}
+		manifestDiff, err := d.diffManifest(pctx, desired, values)
+		if err != nil {
+			return err
+		}
+		diffs = append(diffs, manifestDiff)
+	}
+
+	changed := false
+	for _, diff := range diffs {
+		if diff != "" {
+			changed = true
+			fmt.Fprint(pctx.Out(), diff)
+		}
+	}
+	if !changed {
+		pctx.Infof("No changes for helmrequest %s", desired.Name)
+	}
+	return nil
+}
+
+// diffManifest render the chart of the deployed release with the desired values and diff it with
+// the deployed manifest
+func (d *diffPrinter) diffManifest(pctx *plugin.CaptainContext, desired *v1alpha1.HelmRequest, values map[string]interface{}) (string, error) {
+	name, namespace := plugin.ReleaseName(desired)
+	rel, err := pctx.GetDeployedRelease(name, namespace)
+	if err != nil {
+		pctx.Warningf("helmrequest %s has no deployed release, skip the manifest diff", desired.Name)
+		return "", nil
+	}
+
+	decoded, err := plugin.DecodeRelease(rel)
+	if err != nil {
+		return "", err
+	}
+
+	if decoded.Chart == nil {
+		pctx.Warningf("release %s has no chart, skip the manifest diff", decoded.Name)
+		return "", nil
+	}
+	if desired.Spec.Version != "" && (decoded.Chart.Metadata == nil || decoded.Chart.Metadata.Version != desired.Spec.Version) {
+		pctx.Warningf("the manifest is rendered by the deployed chart %s instead of version %s", chartVersion(decoded.Chart), desired.Spec.Version)
+	}
+
+	rendered, err := plugin.Render(decoded.Chart, values, decoded.Name, decoded.Namespace, true)
+	if err != nil {
+		return "", err
+	}
+	return plugin.DiffManifests("current", "desired", decoded.Manifest, rendered.Manifest)
+}
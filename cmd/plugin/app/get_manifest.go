@@ -2,9 +2,17 @@ package app
 
 import (
 	"fmt"
+	"github.com/alauda/helm-crds/pkg/apis/app/v1alpha1"
 	"github.com/alauda/kubectl-captain/pkg/plugin"
 	"github.com/spf13/cobra"
+	rspb "helm.sh/helm/pkg/release"
+	"io/ioutil"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
 	"k8s.io/klog"
+	"os"
+	"path/filepath"
+	"strings"
 )
 
 var (
@@ -14,15 +22,36 @@ var (
 	
 	# redirect to manifest to a file
 	kubectl captain get-manifest foo -n default > foo.yaml
+
+	# get the resources in the manifest as a json list
+	kubectl captain get-manifest foo -n default -o json
+
+	# get the deployments in the manifest of revision 2, with the hooks
+	kubectl captain get-manifest foo -n default --revision=2 --kind=Deployment --hooks
+
+	# write each resource to it's own file
+	kubectl captain get-manifest foo -n default --split-dir=./foo
 `
 )
 
 type GetManifestOption struct {
+	// revision of the release, 0 means the deployed one
+	revision int
+	hooks    bool
+	kind     string
+	name     string
+	splitDir string
+
+	printFlags *genericclioptions.PrintFlags
+	printer    printers.ResourcePrinter
+
 	pctx *plugin.CaptainContext
 }
 
 func NewGetManifestOption() *GetManifestOption {
-	return &GetManifestOption{}
+	return &GetManifestOption{
+		printFlags: newPrintFlags(""),
+	}
 }
 
 func NewGetManifestCommand() *cobra.Command {
@@ -49,19 +78,32 @@ func NewGetManifestCommand() *cobra.Command {
 		},
 	}
 
+	cmd.Flags().IntVar(&opts.revision, "revision", 0, "get the manifest of this release revision instead of the deployed one")
+	cmd.Flags().BoolVar(&opts.hooks, "hooks", false, "also get the hooks of the release")
+	cmd.Flags().StringVar(&opts.kind, "kind", "", "only get the resources of this kind")
+	cmd.Flags().StringVar(&opts.name, "name", "", "only get the resources with this name")
+	cmd.Flags().StringVar(&opts.splitDir, "split-dir", "", "write each resource to it's own file in this directory instead of printing them")
+	opts.printFlags.AddFlags(cmd)
 	return cmd
 }
 
-func (opts *GetManifestOption) Complete(pctx *plugin.CaptainContext) error {
+func (opts *GetManifestOption) Complete(pctx *plugin.CaptainContext) (err error) {
 	opts.pctx = pctx
-	return nil
+	opts.printer, err = opts.printFlags.ToPrinter()
+	return err
 }
 
 func (opts *GetManifestOption) Validate() error {
+	if opts.revision < 0 {
+		return fmt.Errorf("--revision must be positive")
+	}
+	if opts.splitDir != "" && outputSpecified(opts.printFlags) {
+		return fmt.Errorf("--split-dir can not be used with --output")
+	}
 	return nil
 }
 
-// Run rollback a helmrequest
+// Run get the manifest of a helmrequest's release
 func (opts *GetManifestOption) Run(args []string) (err error) {
 	if opts.pctx == nil {
 		klog.Errorf("GetManifestOption.ctx should not be nil")
@@ -78,27 +120,103 @@ func (opts *GetManifestOption) Run(args []string) (err error) {
 		return err
 	}
 
-	name := hr.Spec.ReleaseName
-	if name == "" {
-		name = hr.Name
+	name, ns := plugin.ReleaseName(hr)
+	var rel *v1alpha1.Release
+	if opts.revision > 0 {
+		rel, err = pctx.GetRelease(name, ns, opts.revision)
+	} else {
+		rel, err = pctx.GetDeployedRelease(name, ns)
 	}
-	ns := hr.Spec.Namespace
-	if ns == "" {
-		ns = hr.Namespace
+	if err != nil {
+		return err
 	}
 
-	rel, err := pctx.GetDeployedRelease(name, ns)
+	decoded, err := plugin.DecodeRelease(rel)
 	if err != nil {
 		return err
 	}
 
-	decoded, err := plugin.DecodeRelease(rel)
+	manifest := decoded.Manifest
+	if opts.hooks {
+		manifest += hooksManifest(decoded.Hooks)
+	}
+
+	docs, err := plugin.ParseManifest(manifest)
+	if err != nil {
+		return err
+	}
+	filtered := opts.kind != "" || opts.name != ""
+	docs = plugin.FilterManifest(docs, opts.kind, opts.name)
+
+	if opts.splitDir != "" {
+		return writeManifestDocs(pctx, opts.splitDir, docs)
+	}
+
+	if filtered || opts.hooks {
+		manifest = plugin.JoinManifest(docs)
+	}
+	if !outputSpecified(opts.printFlags) {
+		fmt.Fprint(pctx.Out(), manifest)
+		return nil
+	}
+
+	list, err := plugin.ManifestToList(manifest)
 	if err != nil {
 		return err
 	}
+	return opts.printer.PrintObj(list, pctx.Out())
 
-	fmt.Print(decoded.Manifest)
+}
 
+// hooksManifest join the hooks to a manifest, in the same format as the resources
+func hooksManifest(hooks []*rspb.Hook) string {
+	var b strings.Builder
+	for _, hook := range hooks {
+		fmt.Fprintf(&b, "---\n# Source: %s\n%s\n", hook.Path, hook.Manifest)
+	}
+	return b.String()
+}
+
+// writeManifestDocs write each resource to <dir>/<kind>-[<namespace>-]<name>.yaml
+func writeManifestDocs(pctx *plugin.CaptainContext, dir string, docs []plugin.ManifestDoc) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for i, name := range manifestDocFiles(docs) {
+		file := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(file, []byte(docs[i].Content), 0644); err != nil {
+			return err
+		}
+		pctx.Infof("wrote %s", file)
+	}
 	return nil
+}
 
+// manifestDocFiles returns the file name of each resource, a number is added to the names already taken
+// so no resource overwrites another
+func manifestDocFiles(docs []plugin.ManifestDoc) []string {
+	taken := map[string]bool{}
+	var files []string
+	for _, doc := range docs {
+		kind, name := doc.Kind, doc.Name
+		if kind == "" {
+			kind = "unknown"
+		}
+		if name == "" {
+			name = strings.TrimSuffix(filepath.Base(doc.Source), filepath.Ext(doc.Source))
+		}
+
+		base := strings.ToLower(kind) + "-" + name
+		if doc.Namespace != "" {
+			base = strings.ToLower(kind) + "-" + doc.Namespace + "-" + name
+		}
+		file := base + ".yaml"
+		for i := 2; taken[file]; i++ {
+			file = fmt.Sprintf("%s-%d.yaml", base, i)
+		}
+		taken[file] = true
+		files = append(files, file)
+	}
+	return files
 }
@@ -0,0 +1,22 @@
+package app
+
+import (
+	"github.com/alauda/kubectl-captain/pkg/plugin"
+	"github.com/gsamokovarov/assert"
+	"testing"
+)
+
+func TestManifestDocFiles(t *testing.T) {
+	docs := []plugin.ManifestDoc{
+		{Kind: "ConfigMap", Name: "foo"},
+		{Kind: "ConfigMap", Name: "foo", Namespace: "kube-system"},
+		{Kind: "ConfigMap", Name: "foo"},
+		{Source: "demo/templates/NOTES.txt"},
+	}
+	assert.Equal(t, []string{
+		"configmap-foo.yaml",
+		"configmap-kube-system-foo.yaml",
+		"configmap-foo-2.yaml",
+		"unknown-NOTES.yaml",
+	}, manifestDocFiles(docs))
+}
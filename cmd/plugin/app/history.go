@@ -0,0 +1,149 @@
+package app
+
+import (
+	"fmt"
+	"github.com/alauda/kubectl-captain/pkg/plugin"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
+	"k8s.io/klog"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+var (
+	historyExample = `
+	# list the revision history of helmrequest foo
+	kubectl captain history foo -n default
+
+	# rollback to one of the listed revisions
+	kubectl captain rollback foo -n default --to-revision=2
+`
+)
+
+type HistoryOption struct {
+	printFlags *genericclioptions.PrintFlags
+	printer    printers.ResourcePrinter
+
+	pctx *plugin.CaptainContext
+}
+
+func NewHistoryOption() *HistoryOption {
+	return &HistoryOption{
+		printFlags: newPrintFlags(""),
+	}
+}
+
+func NewHistoryCommand() *cobra.Command {
+	opts := NewHistoryOption()
+
+	cmd := &cobra.Command{
+		Use:     "history",
+		Short:   "list the revision history of a helmrequest",
+		Example: historyExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := opts.Complete(pctx); err != nil {
+				return err
+			}
+
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+
+			if err := opts.Run(args); err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+
+	opts.printFlags.AddFlags(cmd)
+	return cmd
+}
+
+func (opts *HistoryOption) Complete(pctx *plugin.CaptainContext) (err error) {
+	opts.pctx = pctx
+	opts.printer, err = opts.printFlags.ToPrinter()
+	return err
+}
+
+func (opts *HistoryOption) Validate() error {
+	return nil
+}
+
+// Run print the revisions stored in the helmrequest, oldest first
+func (opts *HistoryOption) Run(args []string) (err error) {
+	if opts.pctx == nil {
+		klog.Errorf("HistoryOption.ctx should not be nil")
+		return fmt.Errorf("HistoryOption.ctx should not be nil")
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("user should input a helmrequest name to list history")
+	}
+
+	pctx := opts.pctx
+	hr, err := pctx.GetHelmRequest(args[0])
+	if err != nil {
+		return err
+	}
+
+	history, err := plugin.GetHistory(hr)
+	if err != nil {
+		return err
+	}
+
+	if len(history) == 0 {
+		return fmt.Errorf("no history found for helmrequest %s", hr.Name)
+	}
+
+	if outputSpecified(opts.printFlags) {
+		var rows []printRow
+		for _, rev := range history {
+			rows = append(rows, printRow{name: strconv.Itoa(rev.Revision), data: rev})
+		}
+		list, err := rowsToList("Revision", rows)
+		if err != nil {
+			return err
+		}
+		return opts.printer.PrintObj(list, pctx.Out())
+	}
+
+	w := tabwriter.NewWriter(pctx.Out(), 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "REVISION\tUPDATED\tUSER\tCHART\tVERSION\tCOMMAND")
+	for _, rev := range history {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", rev.Revision, rev.Timestamp.Format("2006-01-02 15:04:05"),
+			orNone(rev.User), rev.Spec.Chart, rev.Spec.Version, orNone(rev.Command))
+	}
+	return w.Flush()
+}
+
+// redactedFlags may carry secrets, only their names are recorded. The history annotation is readable by
+// anyone who can get the helmrequest.
+var redactedFlags = map[string]bool{
+	"set":        true,
+	"set-string": true,
+	"password":   true,
+}
+
+// commandLine rebuild the command line from cmd's path, args and changed flags, it's recorded in the history
+func commandLine(cmd *cobra.Command, args []string) string {
+	parts := append([]string{"kubectl", cmd.CommandPath()}, args...)
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		value := f.Value.String()
+		if redactedFlags[f.Name] {
+			value = "<redacted>"
+		}
+		parts = append(parts, fmt.Sprintf("--%s=%s", f.Name, value))
+	})
+	return strings.Join(parts, " ")
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+	return s
+}
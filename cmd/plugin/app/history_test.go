@@ -0,0 +1,14 @@
+package app
+
+import (
+	"github.com/gsamokovarov/assert"
+	"testing"
+)
+
+func TestCommandLine(t *testing.T) {
+	cmd := NewUpgradeCommand()
+	assert.Nil(t, cmd.Flags().Parse([]string{"-v", "1.5.0", "--set=password=hunter2", "--set-string=token=abc"}))
+
+	line := commandLine(cmd, []string{"foo"})
+	assert.Equal(t, "kubectl upgrade foo --set=<redacted> --set-string=<redacted> --version=1.5.0", line)
+}
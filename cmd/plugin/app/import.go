@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/alauda/helm-crds/pkg/apis/app/v1alpha1"
+	"github.com/alauda/helm-crds/pkg/apis/app/v1beta1"
 	"github.com/alauda/kubectl-captain/pkg/plugin"
 	"github.com/ghodss/yaml"
 	"github.com/spf13/cobra"
@@ -13,13 +14,12 @@ import (
 	"k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/util/wait"
-	"k8s.io/client-go/kubernetes"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
 	"k8s.io/klog"
 	"os/exec"
 	"os/user"
 	"strings"
-	"time"
 )
 
 var (
@@ -40,15 +40,19 @@ type ImportOptions struct {
 	// useful in business cluster,
 	createCR bool
 
-	wait    bool
-	timeout int
+	waitFlags
 
 	chart   string
 	version string
+
+	printFlags *genericclioptions.PrintFlags
+	printer    printers.ResourcePrinter
 }
 
 func NewImportOptions() *ImportOptions {
-	return &ImportOptions{}
+	return &ImportOptions{
+		printFlags: newPrintFlags("imported"),
+	}
 }
 
 func NewImportCommand() *cobra.Command {
@@ -73,7 +77,6 @@ func NewImportCommand() *cobra.Command {
 			if err := opts.Run(args); err != nil {
 				return err
 			}
-			klog.Info("Create helmrequest")
 
 			return nil
 
@@ -86,16 +89,17 @@ func NewImportCommand() *cobra.Command {
 	cmd.Flags().StringVarP(&opts.repoNamespace, "repo-namespace", "", "alauda-system", "the ChartRepo resources' namespace")
 	cmd.Flags().StringVarP(&opts.helmBinPath, "helm-bin-path", "", "/usr/local/bin/helm", "the helm binary path")
 	cmd.Flags().BoolVarP(&opts.createCR, "create-chartrepo", "", true, "create chartrepo")
-	cmd.Flags().BoolVarP(&opts.wait, "wait", "w", false, "wait for the helmrequest to be synced")
-	cmd.Flags().IntVarP(&opts.timeout, "timeout", "t", 0, "timeout for the wait")
+	opts.waitFlags.AddFlags(cmd, "helmrequest")
 	cmd.Flags().StringVarP(&opts.chart, "chart", "c", "", "chart to use")
 	cmd.Flags().StringVarP(&opts.version, "version", "v", "", "chart version to use")
+	opts.printFlags.AddFlags(cmd)
 	return cmd
 }
 
-func (opts *ImportOptions) Complete(pctx *plugin.CaptainContext) error {
+func (opts *ImportOptions) Complete(pctx *plugin.CaptainContext) (err error) {
 	opts.pctx = pctx
-	return nil
+	opts.printer, err = opts.printFlags.ToPrinter()
+	return err
 }
 
 func (opts *ImportOptions) Validate() error {
@@ -116,7 +120,7 @@ func (opts *ImportOptions) Run(args []string) (err error) {
 	name := args[0]
 
 	pctx := opts.pctx
-	klog.Infof("Target namespace: %s", pctx.GetNamespace())
+	pctx.Infof("Target namespace: %s", pctx.GetNamespace())
 	// get values
 	out, err := exec.Command(opts.helmBinPath, "get", "values", name).Output()
 	if err != nil {
@@ -136,12 +140,12 @@ func (opts *ImportOptions) Run(args []string) (err error) {
 	}
 
 	if opts.chart != "" {
-		klog.Info("Use chart from flag: ", opts.chart)
+		pctx.Infof("Use chart from flag: %s", opts.chart)
 		chart = opts.chart
 	}
 
 	if opts.version != "" {
-		klog.Info("Use version from flag: ", opts.version)
+		pctx.Infof("Use version from flag: %s", opts.version)
 		version = opts.version
 	}
 
@@ -150,7 +154,7 @@ func (opts *ImportOptions) Run(args []string) (err error) {
 		_, err = opts.pctx.GetChartRepo(opts.repoName, opts.repoNamespace)
 		if err != nil {
 			if apierrors.IsNotFound(err) {
-				klog.Info("Create chart repo: ", opts.repoName)
+				pctx.Infof("Create chart repo: %s", opts.repoName)
 				if err := opts.createChartRepo(opts.repoName, opts.repoNamespace); err != nil {
 					return err
 				}
@@ -158,7 +162,7 @@ func (opts *ImportOptions) Run(args []string) (err error) {
 				return err
 			}
 		} else {
-			klog.Info("Using exiting chartrepo")
+			pctx.Infof("Using exiting chartrepo")
 		}
 
 	}
@@ -185,29 +189,19 @@ func (opts *ImportOptions) Run(args []string) (err error) {
 		},
 	}
 
-	_, err = pctx.CreateHelmRequest(&hr)
-	if !opts.wait {
-		if err == nil {
-			klog.Info("Create helmrequest: ", hr.GetName())
-		}
+	created, err := pctx.CreateHelmRequest(&hr)
+	if err != nil {
 		return err
 	}
-
-	klog.Info("Start wait for helmrequest to be synced")
-
-	f := func() (done bool, err error) {
-		result, err := pctx.GetHelmRequest(hr.GetName())
-		if err != nil {
-			return false, err
-		}
-		return result.Status.Phase == "Synced", nil
+	if !opts.wait {
+		return opts.printer.PrintObj(created, pctx.Out())
 	}
 
-	if opts.timeout != 0 {
-		return wait.Poll(1*time.Second, time.Duration(opts.timeout)*time.Second, f)
-	} else {
-		return wait.PollInfinite(1*time.Second, f)
+	synced, err := waitHelmRequestSynced(pctx, created, opts.waitFlags)
+	if err != nil {
+		return err
 	}
+	return opts.printer.PrintObj(synced, pctx.Out())
 
 }
 
@@ -246,16 +240,15 @@ func (opts *ImportOptions) createChartRepo(name, namespace string) error {
 
 	for _, repo := range repos.Repositories {
 		if repo.Name == name {
-			klog.Info("Found repo in helm: ", name)
-			secretName := ""
-			if repo.Password != "" {
-				klog.Info("Create secret for repo")
-				if err := opts.createRepoSecret(repo.Username, repo.Password, name); err != nil {
-					return err
-				}
-				secretName = name
+			opts.pctx.Infof("Found repo in helm: %s", name)
+			creds := plugin.RepoCredentials{Username: repo.Username, Password: repo.Password}
+			if err := readTLSFiles(&creds, repo.CAFile, repo.CertFile, repo.KeyFile); err != nil {
+				return err
 			}
-			if err := opts.createChartRepoResource(repo.URL, secretName); err != nil {
+			if !creds.IsEmpty() {
+				opts.pctx.Infof("Create secret for repo")
+			}
+			if err := opts.createChartRepoResource(repo.URL, name, creds); err != nil {
 				return err
 			}
 
@@ -265,56 +258,31 @@ func (opts *ImportOptions) createChartRepo(name, namespace string) error {
 
 }
 
-// createChartRepo create a new ChartRepo resource
-func (opts *ImportOptions) createChartRepoResource(url string, secretName string) error {
-	cr := v1alpha1.ChartRepo{
+// createChartRepo create a new ChartRepo resource, and the secret named secretName holding creds if any
+func (opts *ImportOptions) createChartRepoResource(url string, secretName string, creds plugin.RepoCredentials) error {
+	cr := v1beta1.ChartRepo{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "ChartRepo",
-			APIVersion: "app.alauda.io/v1alpha1",
+			APIVersion: "app.alauda.io/v1beta1",
 		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      opts.repoName,
 			Namespace: opts.repoNamespace,
 		},
-		Spec: v1alpha1.ChartRepoSpec{
-			URL: url,
+		Spec: v1beta1.ChartRepoSpec{
+			URL:  url,
+			Type: string(v1beta1.ChartRepoChart),
 		},
-		Status: v1alpha1.ChartRepoStatus{
+		Status: v1beta1.ChartRepoStatus{
 			Phase: "Pending",
 		},
 	}
-	if secretName != "" {
+	if !creds.IsEmpty() {
 		cr.Spec.Secret = &v1.SecretReference{
 			Name: secretName,
 		}
 	}
-	_, err := opts.pctx.CreateChartRepo(&cr)
-	return err
-
-}
-
-func (opts *ImportOptions) createRepoSecret(username, password, name string) error {
-	cli, err := kubernetes.NewForConfig(opts.pctx.GetRestConfig())
-	if err != nil {
-		return err
-	}
-	secret := v1.Secret{
-		TypeMeta: metav1.TypeMeta{
-			Kind:       "Secret",
-			APIVersion: "v1",
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
-			Namespace: opts.repoNamespace,
-		},
-		Data: make(map[string][]byte),
-	}
-	//secret.Data["username"] = []byte(base64.StdEncoding.EncodeToString([]byte(username)))
-	//secret.Data["password"] = []byte(base64.StdEncoding.EncodeToString([]byte(password)))
-	secret.Data["username"] = []byte(username)
-	secret.Data["password"] = []byte(password)
-
-	_, err = cli.CoreV1().Secrets(opts.repoNamespace).Create(&secret)
+	_, err := opts.pctx.CreateChartRepoWithSecret(&cr, creds)
 	return err
 
 }
@@ -359,7 +327,7 @@ func (opts *ImportOptions) getChartVersion(name string) (string, string, error)
 	}
 
 	chart, version := parseVersion(chartVersion)
-	klog.Infof("Parsed chart version: %s %s", chart, version)
+	opts.pctx.Infof("Parsed chart version: %s %s", chart, version)
 	return chart, version, nil
 
 }
@@ -0,0 +1,185 @@
+package app
+
+import (
+	"fmt"
+	"github.com/alauda/helm-crds/pkg/apis/app/v1alpha1"
+	"github.com/alauda/kubectl-captain/pkg/plugin"
+	"github.com/spf13/cobra"
+	"io"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
+	"k8s.io/klog"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	listExample = `
+	# list helmrequests in default ns
+	kubectl captain list -n default
+
+	# list failed helmrequests in all namespaces, newest first
+	kubectl captain list -A --phase=Failed --sort-by=age
+`
+)
+
+// sortFuncs are the supported --sort-by keys
+var sortFuncs = map[string]func(a, b *v1alpha1.HelmRequest) bool{
+	"name": func(a, b *v1alpha1.HelmRequest) bool {
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	},
+	"age": func(a, b *v1alpha1.HelmRequest) bool {
+		return b.CreationTimestamp.Before(&a.CreationTimestamp)
+	},
+	"phase": func(a, b *v1alpha1.HelmRequest) bool {
+		return a.Status.Phase < b.Status.Phase
+	},
+	"chart": func(a, b *v1alpha1.HelmRequest) bool {
+		return a.Spec.Chart < b.Spec.Chart
+	},
+	"namespace": func(a, b *v1alpha1.HelmRequest) bool {
+		return a.Spec.Namespace < b.Spec.Namespace
+	},
+}
+
+type ListOption struct {
+	allNamespaces bool
+	selector      string
+	phase         string
+	sortBy        string
+
+	printFlags *genericclioptions.PrintFlags
+	printer    printers.ResourcePrinter
+
+	pctx *plugin.CaptainContext
+}
+
+func NewListOption() *ListOption {
+	return &ListOption{
+		printFlags: newPrintFlags(""),
+	}
+}
+
+func NewListCommand() *cobra.Command {
+	opts := NewListOption()
+
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "list helmrequests",
+		Example: listExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := opts.Complete(pctx); err != nil {
+				return err
+			}
+
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+
+			if err := opts.Run(args); err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.allNamespaces, "all-namespaces", "A", false, "list helmrequests across all namespaces")
+	cmd.Flags().StringVarP(&opts.selector, "selector", "l", "", "label selector to filter helmrequests, supports '=', '==', and '!='")
+	cmd.Flags().StringVarP(&opts.phase, "phase", "", "", "only list helmrequests in this phase, eg: Synced, Failed, Pending")
+	cmd.Flags().StringVarP(&opts.sortBy, "sort-by", "", "name", "sort helmrequests by one of: name, age, phase, chart, namespace")
+	opts.printFlags.AddFlags(cmd)
+	return cmd
+}
+
+func (opts *ListOption) Complete(pctx *plugin.CaptainContext) (err error) {
+	opts.pctx = pctx
+	opts.printer, err = opts.printFlags.ToPrinter()
+	return err
+}
+
+func (opts *ListOption) Validate() error {
+	if _, ok := sortFuncs[opts.sortBy]; !ok {
+		return fmt.Errorf("unknown sort key: %s", opts.sortBy)
+	}
+	return nil
+}
+
+// Run list helmrequests with their status
+func (opts *ListOption) Run(args []string) (err error) {
+	if opts.pctx == nil {
+		klog.Errorf("ListOption.ctx should not be nil")
+		return fmt.Errorf("ListOption.ctx should not be nil")
+	}
+
+	pctx := opts.pctx
+	namespace := pctx.GetNamespace()
+	if opts.allNamespaces {
+		namespace = ""
+	}
+
+	list, err := pctx.ListHelmRequests(namespace, opts.selector)
+	if err != nil {
+		return err
+	}
+
+	var items []*v1alpha1.HelmRequest
+	for i := range list.Items {
+		hr := &list.Items[i]
+		if opts.phase != "" && !strings.EqualFold(string(hr.Status.Phase), opts.phase) {
+			continue
+		}
+		items = append(items, hr)
+	}
+
+	less := sortFuncs[opts.sortBy]
+	sort.SliceStable(items, func(i, j int) bool {
+		return less(items[i], items[j])
+	})
+
+	if !outputSpecified(opts.printFlags) {
+		return printHelmRequests(pctx.Out(), items, opts.allNamespaces)
+	}
+
+	var objs []runtime.Object
+	for _, hr := range items {
+		objs = append(objs, hr)
+	}
+	result, err := toList(objs...)
+	if err != nil {
+		return err
+	}
+	return opts.printer.PrintObj(result, pctx.Out())
+}
+
+// printHelmRequests print helmrequests as a table
+func printHelmRequests(out io.Writer, items []*v1alpha1.HelmRequest, withNamespace bool) error {
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	if withNamespace {
+		fmt.Fprint(w, "NAMESPACE\t")
+	}
+	fmt.Fprintln(w, "NAME\tCHART\tVERSION\tPHASE\tTARGET-NAMESPACE\tCLUSTER\tAGE")
+
+	for _, hr := range items {
+		if withNamespace {
+			fmt.Fprintf(w, "%s\t", hr.Namespace)
+		}
+
+		cluster := hr.Spec.ClusterName
+		if hr.Spec.InstallToAllClusters {
+			cluster = "<all>"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", hr.Name, hr.Spec.Chart, orNone(hr.Spec.Version),
+			orNone(string(hr.Status.Phase)), orNone(hr.Spec.Namespace), orNone(cluster),
+			duration.HumanDuration(time.Since(hr.CreationTimestamp.Time)))
+	}
+	return w.Flush()
+}
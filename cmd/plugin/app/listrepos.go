@@ -0,0 +1,168 @@
+package app
+
+import (
+	"fmt"
+	"github.com/alauda/helm-crds/pkg/apis/app/v1beta1"
+	"github.com/alauda/kubectl-captain/pkg/plugin"
+	"github.com/spf13/cobra"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
+	"k8s.io/klog"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	listReposExample = `
+	# list chartrepos in alauda-system ns
+	kubectl captain list-repos -n alauda-system
+
+	# list chartrepos in all namespaces
+	kubectl captain list-repos -A
+`
+)
+
+type ListReposOption struct {
+	allNamespaces bool
+
+	printFlags *genericclioptions.PrintFlags
+	printer    printers.ResourcePrinter
+
+	pctx *plugin.CaptainContext
+}
+
+func NewListReposOption() *ListReposOption {
+	return &ListReposOption{
+		printFlags: newPrintFlags(""),
+	}
+}
+
+func NewListReposCommand() *cobra.Command {
+	opts := NewListReposOption()
+
+	cmd := &cobra.Command{
+		Use:     "list-repos",
+		Short:   "list chartrepos",
+		Example: listReposExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := opts.Complete(pctx); err != nil {
+				return err
+			}
+
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+
+			if err := opts.Run(args); err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.allNamespaces, "all-namespaces", "A", false, "list chartrepos across all namespaces")
+	opts.printFlags.AddFlags(cmd)
+	return cmd
+}
+
+func (opts *ListReposOption) Complete(pctx *plugin.CaptainContext) (err error) {
+	opts.pctx = pctx
+	opts.printer, err = opts.printFlags.ToPrinter()
+	return err
+}
+
+func (opts *ListReposOption) Validate() error {
+	return nil
+}
+
+// Run list chartrepos with their status
+func (opts *ListReposOption) Run(args []string) (err error) {
+	if opts.pctx == nil {
+		klog.Errorf("ListReposOption.ctx should not be nil")
+		return fmt.Errorf("ListReposOption.ctx should not be nil")
+	}
+
+	pctx := opts.pctx
+	namespace := pctx.GetNamespace()
+	if opts.allNamespaces {
+		namespace = ""
+	}
+
+	list, err := pctx.ListChartRepos(namespace)
+	if err != nil {
+		return err
+	}
+
+	items := list.Items
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Namespace != items[j].Namespace {
+			return items[i].Namespace < items[j].Namespace
+		}
+		return items[i].Name < items[j].Name
+	})
+
+	if outputSpecified(opts.printFlags) {
+		var objs []runtime.Object
+		for i := range items {
+			objs = append(objs, &items[i])
+		}
+		result, err := toList(objs...)
+		if err != nil {
+			return err
+		}
+		return opts.printer.PrintObj(result, pctx.Out())
+	}
+
+	// one list for the events of all the chartrepos, instead of one for each
+	events, err := pctx.GetEventsByObject(namespace, "ChartRepo")
+	if err != nil {
+		pctx.Warningf("list events error: %s", err.Error())
+	}
+
+	w := tabwriter.NewWriter(pctx.Out(), 0, 8, 2, ' ', 0)
+	if opts.allNamespaces {
+		fmt.Fprint(w, "NAMESPACE\t")
+	}
+	fmt.Fprintln(w, "NAME\tTYPE\tURL\tPHASE\tREASON\tLAST SYNC\tAGE")
+
+	for i := range items {
+		cr := &items[i]
+		if opts.allNamespaces {
+			fmt.Fprintf(w, "%s\t", cr.Namespace)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", cr.Name, repoType(cr), repoURL(cr),
+			orNone(string(cr.Status.Phase)), orNone(cr.Status.Reason), lastSync(events[cr.UID]),
+			duration.HumanDuration(time.Since(cr.CreationTimestamp.Time)))
+	}
+	return w.Flush()
+}
+
+// repoType returns the type of the chartrepo, the ones created by v1alpha1 have no type and are Chart
+func repoType(cr *v1beta1.ChartRepo) string {
+	if cr.Spec.Type == "" {
+		return string(v1beta1.ChartRepoChart)
+	}
+	return cr.Spec.Type
+}
+
+// repoURL returns the url the charts come from, which is the vcs url for Git and SVN chartrepos
+func repoURL(cr *v1beta1.ChartRepo) string {
+	if cr.Spec.Source != nil && cr.Spec.Source.URL != "" {
+		return cr.Spec.Source.URL
+	}
+	return orNone(cr.Spec.URL)
+}
+
+// lastSync returns how long ago the chartrepo was synced, captain does not record it in the status so
+// it's read from the events of the chartrepo
+func lastSync(events []v1.Event) string {
+	last := plugin.LastSyncTime(events)
+	if last.IsZero() {
+		return "<unknown>"
+	}
+	return duration.HumanDuration(time.Since(last)) + " ago"
+}
@@ -0,0 +1,145 @@
+package app
+
+import (
+	"fmt"
+	"github.com/alauda/helm-crds/pkg/apis/app/v1alpha1"
+	"github.com/alauda/kubectl-captain/pkg/plugin"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/klog"
+)
+
+var (
+	notesExample = `
+	# show the notes of the deployed release of helmrequest foo
+	kubectl captain notes foo -n default
+
+	# show the notes of revision 2
+	kubectl captain notes foo -n default --revision=2
+`
+)
+
+type NotesOption struct {
+	// revision of the release, 0 means the deployed one
+	revision int
+
+	pctx *plugin.CaptainContext
+}
+
+func NewNotesOption() *NotesOption {
+	return &NotesOption{}
+}
+
+func NewNotesCommand() *cobra.Command {
+	opts := NewNotesOption()
+
+	cmd := &cobra.Command{
+		Use:     "notes",
+		Short:   "show the rendered chart notes of a helmrequest",
+		Example: notesExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := opts.Complete(pctx); err != nil {
+				return err
+			}
+
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+
+			if err := opts.Run(args); err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&opts.revision, "revision", 0, "show the notes of this release revision instead of the deployed one")
+	return cmd
+}
+
+func (opts *NotesOption) Complete(pctx *plugin.CaptainContext) error {
+	opts.pctx = pctx
+	return nil
+}
+
+func (opts *NotesOption) Validate() error {
+	if opts.revision < 0 {
+		return fmt.Errorf("--revision must be positive")
+	}
+	return nil
+}
+
+// Run print the notes of the helmrequest's release
+func (opts *NotesOption) Run(args []string) (err error) {
+	if opts.pctx == nil {
+		klog.Errorf("NotesOption.ctx should not be nil")
+		return fmt.Errorf("NotesOption.ctx should not be nil")
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("user should input a helmrequest name to show notes")
+	}
+
+	pctx := opts.pctx
+	hr, err := pctx.GetHelmRequest(args[0])
+	if err != nil {
+		return err
+	}
+
+	notes, err := releaseNotes(pctx, hr, opts.revision)
+	if err != nil {
+		return err
+	}
+	if notes == "" {
+		pctx.Infof("The chart of helmrequest %s has no notes", hr.Name)
+		return nil
+	}
+	fmt.Fprintln(pctx.Out(), notes)
+	return nil
+}
+
+// releaseNotes returns the notes of a release of hr, revision 0 means the deployed one
+func releaseNotes(pctx *plugin.CaptainContext, hr *v1alpha1.HelmRequest, revision int) (string, error) {
+	name, ns := plugin.ReleaseName(hr)
+	var rel *v1alpha1.Release
+	var err error
+	if revision > 0 {
+		rel, err = pctx.GetRelease(name, ns, revision)
+	} else {
+		rel, err = pctx.GetDeployedRelease(name, ns)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if rel.Status.Notes != "" {
+		return rel.Status.Notes, nil
+	}
+	decoded, err := plugin.DecodeRelease(rel)
+	if err != nil {
+		return "", err
+	}
+	return decoded.Info.Notes, nil
+}
+
+// addNotesFlag add --no-notes to the commands which print the notes after a successful wait
+func addNotesFlag(cmd *cobra.Command, noNotes *bool) {
+	cmd.Flags().BoolVar(noNotes, "no-notes", false, "do not print the chart notes after the helmrequest is synced")
+}
+
+// printSyncedNotes print the notes of the deployed release after hr is synced. Nothing is printed when
+// a structured output is asked, and a failure to get the notes is only a warning as the sync succeeded.
+func printSyncedNotes(pctx *plugin.CaptainContext, hr *v1alpha1.HelmRequest, noNotes bool, printFlags *genericclioptions.PrintFlags) {
+	if noNotes || outputSpecified(printFlags) {
+		return
+	}
+
+	notes, err := releaseNotes(pctx, hr, 0)
+	if err != nil {
+		pctx.Warningf("get notes of helmrequest %s error: %s", hr.Name, err.Error())
+		return
+	}
+	if notes != "" {
+		fmt.Fprintf(pctx.Out(), "NOTES:\n%s\n", notes)
+	}
+}
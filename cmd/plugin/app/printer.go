@@ -0,0 +1,70 @@
+package app
+
+import (
+	"encoding/json"
+	"github.com/alauda/helm-crds/pkg/client/clientset/versioned/scheme"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// newPrintFlags returns PrintFlags which knows captain's resources, operation is used when printing names,
+// eg: helmrequest.app.alauda.io/foo created
+func newPrintFlags(operation string) *genericclioptions.PrintFlags {
+	return genericclioptions.NewPrintFlags(operation).WithTypeSetter(scheme.Scheme)
+}
+
+// outputSpecified tells whether the user asked for a structured output by -o
+func outputSpecified(flags *genericclioptions.PrintFlags) bool {
+	return flags.OutputFormat != nil && *flags.OutputFormat != ""
+}
+
+// toList build a v1 List from typed objects like kubectl get does, printers can't handle typed lists
+func toList(objs ...runtime.Object) (*unstructured.UnstructuredList, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetAPIVersion("v1")
+	list.SetKind("List")
+
+	for _, obj := range objs {
+		gvks, _, err := scheme.Scheme.ObjectKinds(obj)
+		if err != nil {
+			return nil, err
+		}
+		obj.GetObjectKind().SetGroupVersionKind(gvks[0])
+
+		data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, unstructured.Unstructured{Object: data})
+	}
+	return list, nil
+}
+
+// printRow is a row of a table printed by a command, name is what -o name prints for it
+type printRow struct {
+	name string
+	data interface{}
+}
+
+// rowsToList build a v1 List of the rows of a table with the given kind, so -o prints them like resources
+func rowsToList(kind string, rows []printRow) (*unstructured.UnstructuredList, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetAPIVersion("v1")
+	list.SetKind("List")
+
+	for _, row := range rows {
+		data, err := json.Marshal(row.data)
+		if err != nil {
+			return nil, err
+		}
+		item := unstructured.Unstructured{}
+		if err := json.Unmarshal(data, &item.Object); err != nil {
+			return nil, err
+		}
+		item.SetKind(kind)
+		item.SetName(row.name)
+		list.Items = append(list.Items, item)
+	}
+	return list, nil
+}
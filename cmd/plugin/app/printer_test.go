@@ -0,0 +1,30 @@
+package app
+
+import (
+	"bytes"
+	"github.com/gsamokovarov/assert"
+	"testing"
+)
+
+func TestRowsToList(t *testing.T) {
+	rows := []printRow{
+		{name: "1", data: map[string]interface{}{"revision": 1, "user": "tom"}},
+		{name: "2", data: map[string]interface{}{"revision": 2, "user": "lisa"}},
+	}
+	list, err := rowsToList("Revision", rows)
+	assert.Nil(t, err)
+
+	for output, want := range map[string]string{
+		"name":                      "revision/1\nrevision/2\n",
+		"jsonpath={.items[*].user}": "tom lisa",
+	} {
+		flags := newPrintFlags("")
+		flags.OutputFormat = &output
+		printer, err := flags.ToPrinter()
+		assert.Nil(t, err)
+
+		var out bytes.Buffer
+		assert.Nil(t, printer.PrintObj(list, &out))
+		assert.Equal(t, want, out.String())
+	}
+}
@@ -0,0 +1,160 @@
+package app
+
+import (
+	"fmt"
+	"github.com/alauda/kubectl-captain/pkg/plugin"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
+	"k8s.io/klog"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	releaseHistoryExample = `
+	# list all the releases captain kept for helmrequest foo
+	kubectl captain release history foo -n default
+`
+)
+
+// NewReleaseCommand is the parent of the commands working on the Release resources of a helmrequest
+func NewReleaseCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "release",
+		Short: "inspect the releases captain kept for a helmrequest",
+	}
+
+	cmd.AddCommand(NewReleaseHistoryCommand())
+	cmd.AddCommand(NewReleaseDiffCommand())
+	cmd.AddCommand(NewReleaseExportCommand())
+	return cmd
+}
+
+// releaseInfo is a row of release history
+type releaseInfo struct {
+	Revision    int       `json:"revision"`
+	Updated     time.Time `json:"updated"`
+	Status      string    `json:"status"`
+	Chart       string    `json:"chart"`
+	AppVersion  string    `json:"appVersion"`
+	Description string    `json:"description"`
+}
+
+type ReleaseHistoryOption struct {
+	printFlags *genericclioptions.PrintFlags
+	printer    printers.ResourcePrinter
+
+	pctx *plugin.CaptainContext
+}
+
+func NewReleaseHistoryOption() *ReleaseHistoryOption {
+	return &ReleaseHistoryOption{
+		printFlags: newPrintFlags(""),
+	}
+}
+
+func NewReleaseHistoryCommand() *cobra.Command {
+	opts := NewReleaseHistoryOption()
+
+	cmd := &cobra.Command{
+		Use:     "history",
+		Short:   "list all the releases of a helmrequest, including the superseded and failed ones",
+		Example: releaseHistoryExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := opts.Complete(pctx); err != nil {
+				return err
+			}
+
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+
+			if err := opts.Run(args); err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+
+	opts.printFlags.AddFlags(cmd)
+	return cmd
+}
+
+func (opts *ReleaseHistoryOption) Complete(pctx *plugin.CaptainContext) (err error) {
+	opts.pctx = pctx
+	opts.printer, err = opts.printFlags.ToPrinter()
+	return err
+}
+
+func (opts *ReleaseHistoryOption) Validate() error {
+	return nil
+}
+
+// Run print the releases of the helmrequest, oldest first
+func (opts *ReleaseHistoryOption) Run(args []string) (err error) {
+	if opts.pctx == nil {
+		klog.Errorf("ReleaseHistoryOption.ctx should not be nil")
+		return fmt.Errorf("ReleaseHistoryOption.ctx should not be nil")
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("user should input a helmrequest name to list releases")
+	}
+
+	pctx := opts.pctx
+	hr, err := pctx.GetHelmRequest(args[0])
+	if err != nil {
+		return err
+	}
+
+	name, ns := plugin.ReleaseName(hr)
+	releases, err := pctx.ListReleases(name, ns)
+	if err != nil {
+		return err
+	}
+
+	if len(releases) == 0 {
+		return fmt.Errorf("no release found for helmrequest %s", hr.Name)
+	}
+
+	var infos []releaseInfo
+	for i := range releases {
+		rel := &releases[i]
+		info := releaseInfo{
+			Revision:    rel.Spec.Version,
+			Updated:     rel.Status.LastDeployed.Time,
+			Status:      string(rel.Status.Status),
+			Description: rel.Status.Description,
+		}
+
+		decoded, err := plugin.DecodeRelease(rel)
+		if err != nil {
+			pctx.Warningf("decode release %s error: %s", rel.Name, err.Error())
+		} else if decoded.Chart != nil && decoded.Chart.Metadata != nil {
+			info.Chart = chartVersion(decoded.Chart)
+			info.AppVersion = decoded.Chart.Metadata.AppVersion
+		}
+		infos = append(infos, info)
+	}
+
+	if outputSpecified(opts.printFlags) {
+		var rows []printRow
+		for i := range infos {
+			rows = append(rows, printRow{name: releases[i].Name, data: infos[i]})
+		}
+		list, err := rowsToList("Release", rows)
+		if err != nil {
+			return err
+		}
+		return opts.printer.PrintObj(list, pctx.Out())
+	}
+
+	w := tabwriter.NewWriter(pctx.Out(), 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "REVISION\tUPDATED\tSTATUS\tCHART\tAPP VERSION\tDESCRIPTION")
+	for _, info := range infos {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", info.Revision, info.Updated.Format("2006-01-02 15:04:05"),
+			info.Status, orNone(info.Chart), orNone(info.AppVersion), orNone(info.Description))
+	}
+	return w.Flush()
+}
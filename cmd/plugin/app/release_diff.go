@@ -0,0 +1,132 @@
+package app
+
+import (
+	"fmt"
+	"github.com/alauda/kubectl-captain/pkg/plugin"
+	"github.com/spf13/cobra"
+	"helm.sh/helm/pkg/chart"
+	rspb "helm.sh/helm/pkg/release"
+	"k8s.io/klog"
+	"strconv"
+)
+
+var (
+	releaseDiffExample = `
+	# show what changed between revision 2 and 3 of helmrequest foo
+	kubectl captain release diff foo 2 3 -n default
+`
+)
+
+type ReleaseDiffOption struct {
+	pctx *plugin.CaptainContext
+}
+
+func NewReleaseDiffOption() *ReleaseDiffOption {
+	return &ReleaseDiffOption{}
+}
+
+func NewReleaseDiffCommand() *cobra.Command {
+	opts := NewReleaseDiffOption()
+
+	cmd := &cobra.Command{
+		Use:     "diff",
+		Short:   "show the chart, values and manifest changes between two releases of a helmrequest",
+		Example: releaseDiffExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := opts.Complete(pctx); err != nil {
+				return err
+			}
+
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+
+			if err := opts.Run(args); err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func (opts *ReleaseDiffOption) Complete(pctx *plugin.CaptainContext) error {
+	opts.pctx = pctx
+	return nil
+}
+
+func (opts *ReleaseDiffOption) Validate() error {
+	return nil
+}
+
+// Run print the chart version change, the values diff and the per resource manifest diff from revA to revB
+func (opts *ReleaseDiffOption) Run(args []string) (err error) {
+	if opts.pctx == nil {
+		klog.Errorf("ReleaseDiffOption.ctx should not be nil")
+		return fmt.Errorf("ReleaseDiffOption.ctx should not be nil")
+	}
+
+	if len(args) != 3 {
+		return fmt.Errorf("user should input a helmrequest name and two revisions to diff")
+	}
+
+	var revisions [2]int
+	for i, arg := range args[1:] {
+		if revisions[i], err = strconv.Atoi(arg); err != nil || revisions[i] <= 0 {
+			return fmt.Errorf("invalid revision %q, should be a positive integer", arg)
+		}
+	}
+
+	pctx := opts.pctx
+	hr, err := pctx.GetHelmRequest(args[0])
+	if err != nil {
+		return err
+	}
+
+	name, ns := plugin.ReleaseName(hr)
+	var decoded [2]*rspb.Release
+	for i, revision := range revisions {
+		rel, err := pctx.GetRelease(name, ns, revision)
+		if err != nil {
+			return fmt.Errorf("get revision %d of helmrequest %s error: %s", revision, hr.Name, err.Error())
+		}
+		if decoded[i], err = plugin.DecodeRelease(rel); err != nil {
+			return err
+		}
+	}
+
+	a, b := decoded[0], decoded[1]
+	from, to := fmt.Sprintf("revision-%d", revisions[0]), fmt.Sprintf("revision-%d", revisions[1])
+
+	if chartA, chartB := chartVersion(a.Chart), chartVersion(b.Chart); chartA == chartB {
+		fmt.Fprintf(pctx.Out(), "Chart: %s (unchanged)\n", chartA)
+	} else {
+		fmt.Fprintf(pctx.Out(), "Chart: %s -> %s\n", chartA, chartB)
+	}
+
+	valuesDiff, err := plugin.DiffYAML(from+"/values.yaml", to+"/values.yaml", a.Config, b.Config)
+	if err != nil {
+		return err
+	}
+	manifestDiff, err := plugin.DiffManifests(from, to, a.Manifest, b.Manifest)
+	if err != nil {
+		return err
+	}
+
+	if valuesDiff == "" && manifestDiff == "" {
+		pctx.Infof("No values or manifest changes between revision %d and %d", revisions[0], revisions[1])
+		return nil
+	}
+	fmt.Fprint(pctx.Out(), valuesDiff)
+	fmt.Fprint(pctx.Out(), manifestDiff)
+	return nil
+}
+
+// chartVersion returns <name>-<version> of the chart
+func chartVersion(ch *chart.Chart) string {
+	if ch == nil || ch.Metadata == nil {
+		return "<none>"
+	}
+	return ch.Metadata.Name + "-" + ch.Metadata.Version
+}
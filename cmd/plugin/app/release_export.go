@@ -0,0 +1,119 @@
+package app
+
+import (
+	"fmt"
+	"github.com/alauda/helm-crds/pkg/apis/app/v1alpha1"
+	"github.com/alauda/kubectl-captain/pkg/plugin"
+	"github.com/spf13/cobra"
+	"k8s.io/klog"
+)
+
+var (
+	releaseExportExample = `
+	# export the chart and values of the deployed release of helmrequest foo to ./foo
+	kubectl captain release export foo -n default --output-dir=./foo
+
+	# export revision 2 as a chart archive
+	kubectl captain release export foo -n default --revision=2 --tgz
+`
+)
+
+type ReleaseExportOption struct {
+	// revision of the release, 0 means the deployed one
+	revision  int
+	outputDir string
+	tgz       bool
+
+	pctx *plugin.CaptainContext
+}
+
+func NewReleaseExportOption() *ReleaseExportOption {
+	return &ReleaseExportOption{}
+}
+
+func NewReleaseExportCommand() *cobra.Command {
+	opts := NewReleaseExportOption()
+
+	cmd := &cobra.Command{
+		Use:     "export",
+		Short:   "write the chart and the user values stored in a release of a helmrequest to local files",
+		Example: releaseExportExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := opts.Complete(pctx); err != nil {
+				return err
+			}
+
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+
+			if err := opts.Run(args); err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&opts.revision, "revision", 0, "export this release revision instead of the deployed one")
+	cmd.Flags().StringVarP(&opts.outputDir, "output-dir", "", ".", "the directory to write the chart and values to")
+	cmd.Flags().BoolVar(&opts.tgz, "tgz", false, "write the chart as a .tgz archive instead of a directory")
+	return cmd
+}
+
+func (opts *ReleaseExportOption) Complete(pctx *plugin.CaptainContext) error {
+	opts.pctx = pctx
+	return nil
+}
+
+func (opts *ReleaseExportOption) Validate() error {
+	if opts.revision < 0 {
+		return fmt.Errorf("--revision must be positive")
+	}
+	if opts.outputDir == "" {
+		return fmt.Errorf("--output-dir should not be empty")
+	}
+	return nil
+}
+
+// Run decode the release and write its chart and user values to the output dir
+func (opts *ReleaseExportOption) Run(args []string) (err error) {
+	if opts.pctx == nil {
+		klog.Errorf("ReleaseExportOption.ctx should not be nil")
+		return fmt.Errorf("ReleaseExportOption.ctx should not be nil")
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("user should input a helmrequest name to export")
+	}
+
+	pctx := opts.pctx
+	hr, err := pctx.GetHelmRequest(args[0])
+	if err != nil {
+		return err
+	}
+
+	name, ns := plugin.ReleaseName(hr)
+	var rel *v1alpha1.Release
+	if opts.revision > 0 {
+		rel, err = pctx.GetRelease(name, ns, opts.revision)
+	} else {
+		rel, err = pctx.GetDeployedRelease(name, ns)
+	}
+	if err != nil {
+		return err
+	}
+
+	decoded, err := plugin.DecodeRelease(rel)
+	if err != nil {
+		return err
+	}
+
+	chartPath, valuesPath, err := plugin.ExportRelease(decoded, opts.outputDir, opts.tgz)
+	if err != nil {
+		return err
+	}
+
+	pctx.Infof("Exported chart %s of release %s revision %d to %s", chartVersion(decoded.Chart), decoded.Name, decoded.Version, chartPath)
+	pctx.Infof("Exported user values to %s", valuesPath)
+	return nil
+}
@@ -3,10 +3,11 @@ package app
 import (
 	"fmt"
 	"github.com/alauda/kubectl-captain/pkg/plugin"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
-	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
 	"k8s.io/klog"
-	"time"
 )
 
 var (
@@ -17,14 +18,18 @@ var (
 )
 
 type ResyncRepoOption struct {
-	wait    bool
-	timeout int
+	waitFlags
+
+	printFlags *genericclioptions.PrintFlags
+	printer    printers.ResourcePrinter
 
 	pctx *plugin.CaptainContext
 }
 
 func NewResyncRepoOption() *ResyncRepoOption {
-	return &ResyncRepoOption{}
+	return &ResyncRepoOption{
+		printFlags: newPrintFlags("resynced"),
+	}
 }
 
 func NewResyncRepoCommand() *cobra.Command {
@@ -50,14 +55,15 @@ func NewResyncRepoCommand() *cobra.Command {
 		},
 	}
 
-	cmd.Flags().BoolVarP(&opts.wait, "wait", "w", false, "wait for the helmrequest to be synced")
-	cmd.Flags().IntVarP(&opts.timeout, "timeout", "t", 0, "timeout for the wait")
+	opts.waitFlags.AddFlags(cmd, "chartrepo")
+	opts.printFlags.AddFlags(cmd)
 	return cmd
 }
 
-func (opts *ResyncRepoOption) Complete(pctx *plugin.CaptainContext) error {
+func (opts *ResyncRepoOption) Complete(pctx *plugin.CaptainContext) (err error) {
 	opts.pctx = pctx
-	return nil
+	opts.printer, err = opts.printFlags.ToPrinter()
+	return err
 }
 
 func (opts *ResyncRepoOption) Validate() error {
@@ -82,41 +88,29 @@ func (opts *ResyncRepoOption) Run(args []string) (err error) {
 
 	repo, err := pctx.GetChartRepo(name, namespace)
 	if err != nil {
-		klog.Error("Get chartrepo error: ", err)
-		return err
+		return errors.Wrap(err, "get chartrepo error")
 	}
 
-	data := `{"status":{"phase":"Pending"}}`
-
 	// for old v1alpha1 data
 	if repo.Spec.Type == "" {
-		data = `{"spec":{"type":"Chart"},"status":{"phase":"Pending"}}`
+		if _, err := pctx.PatchChartRepo(repo.Name, []byte(`{"spec":{"type":"Chart"}}`)); err != nil {
+			return errors.Wrap(err, "update chartrepo error")
+		}
 	}
 
-	_, err = pctx.PatchChartRepo(repo.Name, []byte(data))
+	patched, err := pctx.PatchChartRepoStatus(repo.Name, []byte(`{"status":{"phase":"Pending"}}`))
 	if err != nil {
-		klog.Error("Update chartrepo error: ", err)
-		return err
+		return errors.Wrap(err, "resync chartrepo error")
 	}
 
 	if !opts.wait {
-		return err
+		return opts.printer.PrintObj(patched, pctx.Out())
 	}
 
-	klog.Info("Start wait for chartrepo to be synced")
-
-	f := func() (done bool, err error) {
-		result, err := pctx.GetChartRepo(name, pctx.GetNamespace())
-		if err != nil {
-			return false, err
-		}
-		return result.Status.Phase == "Synced", nil
-	}
-
-	if opts.timeout != 0 {
-		return wait.Poll(1*time.Second, time.Duration(opts.timeout)*time.Second, f)
-	} else {
-		return wait.PollInfinite(1*time.Second, f)
+	synced, err := waitChartRepoSynced(pctx, name, namespace, patched.ResourceVersion, opts.waitFlags)
+	if err != nil {
+		return err
 	}
+	return opts.printer.PrintObj(synced, pctx.Out())
 
 }
@@ -7,27 +7,42 @@ import (
 	"github.com/alauda/helm-crds/pkg/apis/app/v1alpha1"
 	"github.com/alauda/kubectl-captain/pkg/plugin"
 	"github.com/spf13/cobra"
-	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
 	"k8s.io/klog"
-	"time"
 )
 
 var (
 	rollbackExample = `
 	# rollback  helmerequest foo to last configurations
 	kubectl captain rollback foo -n default
+
+	# rollback helmrequest foo to revision 2
+	kubectl captain rollback foo -n default --to-revision=2
 `
 )
 
 type RollbackOption struct {
 	pctx *plugin.CaptainContext
 
-	wait    bool
-	timeout int
+	// revision to rollback to, 0 means the previous one
+	revision int
+
+	waitFlags
+	// noNotes skip the chart notes printed after a successful wait
+	noNotes bool
+
+	// command is recorded in the revision history
+	command string
+
+	printFlags *genericclioptions.PrintFlags
+	printer    printers.ResourcePrinter
 }
 
 func NewRollbackOption() *RollbackOption {
-	return &RollbackOption{}
+	return &RollbackOption{
+		printFlags: newPrintFlags("rolled back"),
+	}
 }
 
 func NewRollbackCommand() *cobra.Command {
@@ -38,7 +53,7 @@ func NewRollbackCommand() *cobra.Command {
 		Short:   "rollback a helmrequest",
 		Example: rollbackExample,
 		RunE: func(cmd *cobra.Command, args []string) error {
-
+			opts.command = commandLine(cmd, args)
 			if err := opts.Complete(pctx); err != nil {
 				return err
 			}
@@ -54,18 +69,24 @@ func NewRollbackCommand() *cobra.Command {
 		},
 	}
 
-	cmd.Flags().BoolVarP(&opts.wait, "wait", "w", false, "wait for the helmrequest to be synced")
-	cmd.Flags().IntVarP(&opts.timeout, "timeout", "t", 0, "timeout for the wait")
+	cmd.Flags().IntVarP(&opts.revision, "to-revision", "", 0, "the revision to rollback to, see 'kubectl captain history', default to the previous one")
+	opts.waitFlags.AddFlags(cmd, "helmrequest")
+	addNotesFlag(cmd, &opts.noNotes)
+	opts.printFlags.AddFlags(cmd)
 
 	return cmd
 }
 
-func (opts *RollbackOption) Complete(pctx *plugin.CaptainContext) error {
+func (opts *RollbackOption) Complete(pctx *plugin.CaptainContext) (err error) {
 	opts.pctx = pctx
-	return nil
+	opts.printer, err = opts.printFlags.ToPrinter()
+	return err
 }
 
 func (opts *RollbackOption) Validate() error {
+	if opts.revision < 0 {
+		return fmt.Errorf("revision should not be negative")
+	}
 	return nil
 }
 
@@ -86,55 +107,75 @@ func (opts *RollbackOption) Run(args []string) (err error) {
 		return err
 	}
 
-	key := "last-spec"
-
-	if hr.Annotations == nil || hr.Annotations[key] == "" {
-		return errors.New("no last configuration found")
+	history, err := plugin.GetHistory(hr)
+	if err != nil {
+		return err
 	}
 
-	data := hr.Annotations[key]
-
 	var new v1alpha1.HelmRequestSpec
-	if err = json.Unmarshal([]byte(data), &new); err != nil {
-		return err
+	target := "last configuration"
+	previousRev, changed := plugin.PreviousRevision(hr, history)
+
+	switch {
+	case opts.revision > 0:
+		rev, err := plugin.FindRevision(history, opts.revision)
+		if err != nil {
+			return err
+		}
+		new = rev.Spec
+		target = fmt.Sprintf("revision %d", rev.Revision)
+	case previousRev != nil:
+		if changed {
+			pctx.Warningf("helmrequest %s has been changed outside kubectl-captain, rollback to the latest recorded revision %d",
+				hr.Name, previousRev.Revision)
+		}
+		new = previousRev.Spec
+		target = fmt.Sprintf("revision %d", previousRev.Revision)
+	case hr.Annotations[plugin.LastSpecAnnotation] != "":
+		// helmrequests changed by older versions of this plugin
+		if err = json.Unmarshal([]byte(hr.Annotations[plugin.LastSpecAnnotation]), &new); err != nil {
+			return err
+		}
+	default:
+		return errors.New("no last configuration found")
 	}
 
+	previous := hr.Spec.DeepCopy()
 	hr.Spec = new
 
-	_, err = pctx.UpdateHelmRequest(hr)
-	if !opts.wait {
+	if err := plugin.RecordRevision(hr, previous, pctx.GetUser(), opts.command, plugin.DefaultHistoryMax); err != nil {
 		return err
 	}
 
-	klog.Info("Start wait for helmrequest to be synced")
-
-	// TEST: should we update status too
-	f := func() (done bool, err error) {
-		result, err := pctx.GetHelmRequest(hr.GetName())
-		if err != nil {
-			return false, err
-		}
-		if result.Status.Phase == "Failed" {
-			return false, errors.New("helmrequest failed, please check it's event to find out why")
-		}
-		return result.Status.Phase == "Synced", nil
+	updated, err := pctx.UpdateHelmRequest(hr)
+	if err != nil {
+		return err
 	}
-
-	if opts.timeout != 0 {
-		err = wait.Poll(1*time.Second, time.Duration(opts.timeout)*time.Second, f)
-	} else {
-		err = wait.PollInfinite(1*time.Second, f)
+	if !opts.wait {
+		return opts.printer.PrintObj(updated, pctx.Out())
 	}
 
+	// the phase left from before the rollback is not the result of it, reset it so captain writes a new one
+	if updated, err = pctx.ResyncHelmRequest(updated.Name); err != nil {
+		return err
+	}
+	synced, err := waitHelmRequestSynced(pctx, updated, opts.waitFlags)
 	if err == nil {
-		klog.Infof("Rollback  to version: %s", new.Version)
-		message := fmt.Sprintf("Rollback helmrequest %s to version %s ", hr.Name, hr.Spec.Version)
+		pctx.Infof("Rollback to %s, version: %s", target, new.Version)
+		message := fmt.Sprintf("Rollback helmrequest %s to %s, version %s ", hr.Name, target, hr.Spec.Version)
 		pctx.CreateEvent("Normal", "Synced", message, hr)
 	} else {
-		message := fmt.Sprintf("Rollback helmrequest %s to version %s error: %s", hr.Name, hr.Spec.Version, err.Error())
+		message := fmt.Sprintf("Rollback helmrequest %s to %s, version %s error: %s", hr.Name, target, hr.Spec.Version, err.Error())
 		pctx.CreateEvent("Warning", "FailedRollback", message, hr)
 	}
+	if err != nil {
+		return err
+	}
 
-	return err
+	if err := opts.printer.PrintObj(synced, pctx.Out()); err != nil {
+		return err
+	}
+	printSyncedNotes(pctx, synced, opts.noNotes, opts.printFlags)
+	return nil
 
 }
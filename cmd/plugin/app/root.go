@@ -13,12 +13,13 @@ var pctx *plugin.CaptainContext = nil
 func NewCaptainCommand(streams genericclioptions.IOStreams) *cobra.Command {
 	pctx = plugin.NewCaptainContext(streams)
 	var ns string
+	var quiet bool
 
 	cmd := &cobra.Command{
 		Use:   "captain",
 		Short: "kubectl captain: access helmrequest/chartrepo resource",
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-			err := pctx.Complete(ns)
+			err := pctx.Complete(ns, quiet)
 			if err != nil {
 				return err
 			}
@@ -27,14 +28,31 @@ func NewCaptainCommand(streams genericclioptions.IOStreams) *cobra.Command {
 	}
 
 	cmd.PersistentFlags().StringVarP(&ns, "namespace", "n", "default", "the working namespace")
+	cmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "only print the results, no progress messages")
+	cmd.SetIn(streams.In)
+	cmd.SetOut(streams.Out)
+	cmd.SetErr(streams.ErrOut)
 	cmd.AddCommand(NewCreateRepoCommand())
 	cmd.AddCommand(NewCreateCommand())
 	cmd.AddCommand(NewGetManifestCommand())
+	cmd.AddCommand(NewListCommand())
+	cmd.AddCommand(NewStatusCommand())
+	cmd.AddCommand(NewNotesCommand())
 	cmd.AddCommand(NewUpgradeCommand())
+	cmd.AddCommand(NewDiffCommand())
+	cmd.AddCommand(NewTemplateCommand())
+	cmd.AddCommand(NewTriggerUpdateCommand())
 	cmd.AddCommand(NewRollbackCommand())
+	cmd.AddCommand(NewDeleteCommand())
+	cmd.AddCommand(NewHistoryCommand())
+	cmd.AddCommand(NewReleaseCommand())
 	cmd.AddCommand(NewImportCommand())
 	cmd.AddCommand(NewVersionCommand())
 	cmd.AddCommand(NewResyncRepoCommand())
+	cmd.AddCommand(NewUpdateRepoCommand())
+	cmd.AddCommand(NewListReposCommand())
+	cmd.AddCommand(NewDescribeRepoCommand())
+	cmd.AddCommand(NewDeleteRepoCommand())
 
 	return cmd
 }
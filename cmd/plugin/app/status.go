@@ -0,0 +1,222 @@
+package app
+
+import (
+	"fmt"
+	"github.com/alauda/helm-crds/pkg/apis/app/v1alpha1"
+	"github.com/alauda/kubectl-captain/pkg/plugin"
+	"github.com/ghodss/yaml"
+	"github.com/spf13/cobra"
+	"io"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
+	"k8s.io/klog"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	statusExample = `
+	# show the status of helmrequest foo, with it's release and events
+	kubectl captain status foo -n default
+`
+)
+
+type StatusOption struct {
+	printFlags *genericclioptions.PrintFlags
+	printer    printers.ResourcePrinter
+
+	pctx *plugin.CaptainContext
+}
+
+func NewStatusOption() *StatusOption {
+	return &StatusOption{
+		printFlags: newPrintFlags(""),
+	}
+}
+
+func NewStatusCommand() *cobra.Command {
+	opts := NewStatusOption()
+
+	cmd := &cobra.Command{
+		Use:     "status",
+		Aliases: []string{"describe"},
+		Short:   "show the status of a helmrequest, it's deployed release and events",
+		Example: statusExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := opts.Complete(pctx); err != nil {
+				return err
+			}
+
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+
+			if err := opts.Run(args); err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+
+	opts.printFlags.AddFlags(cmd)
+	return cmd
+}
+
+func (opts *StatusOption) Complete(pctx *plugin.CaptainContext) (err error) {
+	opts.pctx = pctx
+	opts.printer, err = opts.printFlags.ToPrinter()
+	return err
+}
+
+func (opts *StatusOption) Validate() error {
+	return nil
+}
+
+// Run print the helmrequest, it's deployed release and events
+func (opts *StatusOption) Run(args []string) (err error) {
+	if opts.pctx == nil {
+		klog.Errorf("StatusOption.ctx should not be nil")
+		return fmt.Errorf("StatusOption.ctx should not be nil")
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("user should input a helmrequest name to show status")
+	}
+
+	pctx := opts.pctx
+	hr, err := pctx.GetHelmRequest(args[0])
+	if err != nil {
+		return err
+	}
+
+	if outputSpecified(opts.printFlags) {
+		return opts.printer.PrintObj(hr, pctx.Out())
+	}
+
+	w := tabwriter.NewWriter(pctx.Out(), 0, 8, 2, ' ', 0)
+	defer w.Flush()
+
+	name, ns := plugin.ReleaseName(hr)
+	cluster := hr.Spec.ClusterName
+	if hr.Spec.InstallToAllClusters {
+		cluster = "<all>"
+	}
+
+	fmt.Fprintf(w, "Name:\t%s\n", hr.Name)
+	fmt.Fprintf(w, "Namespace:\t%s\n", hr.Namespace)
+	fmt.Fprintf(w, "Chart:\t%s\n", hr.Spec.Chart)
+	fmt.Fprintf(w, "Version:\t%s\n", orNone(hr.Spec.Version))
+	fmt.Fprintf(w, "Release Name:\t%s\n", name)
+	fmt.Fprintf(w, "Target Namespace:\t%s\n", ns)
+	fmt.Fprintf(w, "Cluster:\t%s\n", orNone(cluster))
+	if len(hr.Status.SyncedClusters) > 0 {
+		fmt.Fprintf(w, "Synced Clusters:\t%s\n", strings.Join(hr.Status.SyncedClusters, ","))
+	}
+	if len(hr.Spec.Dependencies) > 0 {
+		fmt.Fprintf(w, "Dependencies:\t%s\n", strings.Join(hr.Spec.Dependencies, ","))
+	}
+	fmt.Fprintf(w, "Phase:\t%s\n", orNone(string(hr.Status.Phase)))
+
+	fmt.Fprintln(w, "Values:")
+	if len(hr.Spec.Values) == 0 {
+		fmt.Fprintln(w, "  <none>")
+	} else {
+		data, err := yaml.Marshal(hr.Spec.Values)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(w, indent(string(data), "  "))
+	}
+
+	fmt.Fprintln(w, "Values From:")
+	printValuesFrom(w, hr.Spec.ValuesFrom)
+
+	fmt.Fprintln(w, "Release:")
+	rel, err := pctx.GetDeployedRelease(name, ns)
+	if err != nil {
+		fmt.Fprintf(w, "  <none>\t(%s)\n", err.Error())
+	} else if err := printRelease(w, rel); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "Events:")
+	events, err := pctx.GetEvents(hr)
+	if err != nil {
+		return err
+	}
+	printEvents(w, events)
+
+	return nil
+}
+
+func printValuesFrom(w io.Writer, sources []v1alpha1.ValuesFromSource) {
+	if len(sources) == 0 {
+		fmt.Fprintln(w, "  <none>")
+		return
+	}
+
+	fmt.Fprintln(w, "  KIND\tNAME\tKEY\tOPTIONAL")
+	for _, source := range sources {
+		if ref := source.ConfigMapKeyRef; ref != nil {
+			fmt.Fprintf(w, "  ConfigMap\t%s\t%s\t%t\n", ref.Name, ref.Key, ref.Optional != nil && *ref.Optional)
+		}
+		if ref := source.SecretKeyRef; ref != nil {
+			fmt.Fprintf(w, "  Secret\t%s\t%s\t%t\n", ref.Name, ref.Key, ref.Optional != nil && *ref.Optional)
+		}
+	}
+}
+
+func printRelease(w io.Writer, rel *v1alpha1.Release) error {
+	decoded, err := plugin.DecodeRelease(rel)
+	if err != nil {
+		return err
+	}
+
+	chart := "<none>"
+	if decoded.Chart != nil && decoded.Chart.Metadata != nil {
+		chart = decoded.Chart.Metadata.Name + "-" + decoded.Chart.Metadata.Version
+	}
+
+	fmt.Fprintf(w, "  Name:\t%s\n", decoded.Name)
+	fmt.Fprintf(w, "  Revision:\t%d\n", decoded.Version)
+	fmt.Fprintf(w, "  Chart:\t%s\n", chart)
+	fmt.Fprintf(w, "  Status:\t%s\n", decoded.Info.Status)
+	fmt.Fprintf(w, "  First Deployed:\t%s\n", decoded.Info.FirstDeployed.Format(time.RFC3339))
+	fmt.Fprintf(w, "  Last Deployed:\t%s\n", decoded.Info.LastDeployed.Format(time.RFC3339))
+	fmt.Fprintf(w, "  Description:\t%s\n", orNone(decoded.Info.Description))
+	if decoded.Info.Notes != "" {
+		fmt.Fprintln(w, "  Notes:")
+		fmt.Fprint(w, indent(decoded.Info.Notes, "    "))
+	}
+	return nil
+}
+
+// printEvents print events as a timeline, oldest first
+func printEvents(w io.Writer, events []v1.Event) {
+	if len(events) == 0 {
+		fmt.Fprintln(w, "  <none>")
+		return
+	}
+
+	fmt.Fprintln(w, "  LAST SEEN\tTYPE\tREASON\tFROM\tCOUNT\tMESSAGE")
+	for _, event := range events {
+		last := event.LastTimestamp.Time
+		if last.IsZero() {
+			last = event.CreationTimestamp.Time
+		}
+		fmt.Fprintf(w, "  %s ago\t%s\t%s\t%s\t%d\t%s\n", duration.HumanDuration(time.Since(last)), event.Type,
+			event.Reason, event.Source.Component, event.Count, strings.TrimSpace(event.Message))
+	}
+}
+
+// indent prefix every line of s, s will always be ended with a newline
+func indent(s, prefix string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	for i := range lines {
+		lines[i] = prefix + lines[i]
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,127 @@
+package app
+
+import (
+	"fmt"
+	"github.com/alauda/kubectl-captain/pkg/plugin"
+	"github.com/spf13/cobra"
+	"k8s.io/klog"
+)
+
+var (
+	templateExample = `
+	# render the manifest of helmrequest foo locally
+	kubectl captain template foo -n default
+
+	# render the manifest helmrequest foo would have after upgrade to chart version 1.5.0
+	kubectl captain template foo -n default -v 1.5.0 --set=a=b
+
+	# write one file per resource to a directory
+	kubectl captain template foo -n default --output-dir=./foo
+`
+)
+
+type TemplateOption struct {
+	// upgrade computes the spec to render, it takes the same flags as the upgrade command
+	upgrade *UpgradeOption
+
+	repoNamespace string
+	outputDir     string
+
+	pctx *plugin.CaptainContext
+}
+
+func NewTemplateOption() *TemplateOption {
+	return &TemplateOption{
+		upgrade: NewUpdateOption(),
+	}
+}
+
+func NewTemplateCommand() *cobra.Command {
+	opts := NewTemplateOption()
+
+	cmd := &cobra.Command{
+		Use:     "template",
+		Short:   "render the manifest of a helmrequest locally",
+		Example: templateExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := opts.Complete(pctx); err != nil {
+				return err
+			}
+
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+
+			if err := opts.Run(args); err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+
+	opts.upgrade.addSpecFlags(cmd)
+	cmd.Flags().StringVarP(&opts.repoNamespace, "repo-namespace", "", "alauda-system", "the ChartRepo resources' namespace")
+	cmd.Flags().StringVarP(&opts.outputDir, "output-dir", "", "", "write one file per resource to this directory instead of printing the manifest")
+	return cmd
+}
+
+func (opts *TemplateOption) Complete(pctx *plugin.CaptainContext) error {
+	opts.pctx = pctx
+	opts.upgrade.pctx = pctx
+	return nil
+}
+
+func (opts *TemplateOption) Validate() error {
+	return opts.upgrade.Validate()
+}
+
+// Run download the chart of the helmrequest and render it with the merged values
+func (opts *TemplateOption) Run(args []string) (err error) {
+	if opts.pctx == nil {
+		klog.Errorf("TemplateOption.ctx should not be nil")
+		return fmt.Errorf("TemplateOption.ctx should not be nil")
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("user should input helmrequest name to render")
+	}
+
+	pctx := opts.pctx
+	hr, err := pctx.GetHelmRequest(args[0])
+	if err != nil {
+		return err
+	}
+
+	if err := opts.upgrade.applySpec(hr); err != nil {
+		return err
+	}
+
+	pctx.Infof("Download chart %s version %s", hr.Spec.Chart, orNone(hr.Spec.Version))
+	ch, err := pctx.LoadChart(hr.Spec.Chart, hr.Spec.Version, opts.repoNamespace)
+	if err != nil {
+		return err
+	}
+
+	values, err := pctx.ResolveValues(&hr.Spec)
+	if err != nil {
+		return err
+	}
+
+	name, namespace := plugin.ReleaseName(hr)
+	rendered, err := plugin.Render(ch, values, name, namespace, false)
+	if err != nil {
+		return err
+	}
+
+	manifest := rendered.Manifest + hooksManifest(rendered.Hooks)
+	if opts.outputDir != "" {
+		docs, err := plugin.ParseManifest(manifest)
+		if err != nil {
+			return err
+		}
+		return writeManifestDocs(pctx, opts.outputDir, docs)
+	}
+
+	fmt.Fprint(pctx.Out(), manifest)
+	return nil
+}
@@ -0,0 +1,159 @@
+package app
+
+import (
+	"fmt"
+	"github.com/alauda/helm-crds/pkg/apis/app/v1alpha1"
+	"github.com/alauda/kubectl-captain/pkg/plugin"
+	"github.com/spf13/cobra"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
+	"k8s.io/klog"
+	"time"
+)
+
+var (
+	triggerUpdateExample = `
+	# force captain to re-sync helmrequest foo in default ns and wait for it
+	kubectl captain trigger-update foo -n default -w --timeout=60
+
+	# re-sync all the helmrequests labeled with app=nginx
+	kubectl captain trigger-update -n default -l app=nginx
+`
+)
+
+type TriggerUpdateOption struct {
+	selector string
+
+	waitFlags
+
+	printFlags *genericclioptions.PrintFlags
+	printer    printers.ResourcePrinter
+
+	pctx *plugin.CaptainContext
+}
+
+func NewTriggerUpdateOption() *TriggerUpdateOption {
+	return &TriggerUpdateOption{
+		printFlags: newPrintFlags("triggered"),
+	}
+}
+
+func NewTriggerUpdateCommand() *cobra.Command {
+	opts := NewTriggerUpdateOption()
+
+	cmd := &cobra.Command{
+		Use:     "trigger-update",
+		Short:   "trigger update on a helmrequest without changing it's spec",
+		Example: triggerUpdateExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := opts.Complete(pctx); err != nil {
+				return err
+			}
+
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+
+			if err := opts.Run(args); err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.selector, "selector", "l", "", "label selector to filter helmrequests, supports '=', '==', and '!='")
+	opts.waitFlags.AddFlags(cmd, "helmrequest")
+	opts.printFlags.AddFlags(cmd)
+	return cmd
+}
+
+func (opts *TriggerUpdateOption) Complete(pctx *plugin.CaptainContext) (err error) {
+	opts.pctx = pctx
+	opts.printer, err = opts.printFlags.ToPrinter()
+	return err
+}
+
+func (opts *TriggerUpdateOption) Validate() error {
+	return nil
+}
+
+// Run bump the resync annotation of the target helmrequests and reset their phase,
+// so captain will sync them again
+func (opts *TriggerUpdateOption) Run(args []string) (err error) {
+	if opts.pctx == nil {
+		klog.Errorf("TriggerUpdateOption.ctx should not be nil")
+		return fmt.Errorf("TriggerUpdateOption.ctx should not be nil")
+	}
+
+	if len(args) == 0 && opts.selector == "" {
+		return fmt.Errorf("user should input helmrequest names or a label selector to trigger update")
+	}
+	if len(args) > 0 && opts.selector != "" {
+		return fmt.Errorf("helmrequest names and a label selector cannot be used together")
+	}
+
+	pctx := opts.pctx
+
+	names := args
+	if opts.selector != "" {
+		list, err := pctx.ListHelmRequests(pctx.GetNamespace(), opts.selector)
+		if err != nil {
+			return err
+		}
+		if len(list.Items) == 0 {
+			return fmt.Errorf("no helmrequest matches selector %s", opts.selector)
+		}
+		for _, item := range list.Items {
+			names = append(names, item.Name)
+		}
+	}
+
+	var triggered []*v1alpha1.HelmRequest
+	var errs []error
+	for _, name := range names {
+		// the annotation makes captain notice the helmrequest, the phase tells it to sync again. The phase
+		// goes through the status subresource, it's dropped from a patch of the helmrequest itself.
+		data := fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`, resyncAnnotation, time.Now().String())
+		if _, err := pctx.PatchHelmRequest(name, []byte(data)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		hr, err := pctx.ResyncHelmRequest(name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		triggered = append(triggered, hr)
+	}
+
+	if !opts.wait {
+		for _, hr := range triggered {
+			if err := opts.printer.PrintObj(hr, pctx.Out()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return utilerrors.NewAggregate(errs)
+	}
+
+	// --timeout covers all the waits, not each of them
+	flags := opts.waitFlags.withDeadline()
+	for _, hr := range triggered {
+		synced, err := waitHelmRequestSynced(pctx, hr, flags)
+		if err != nil {
+			message := fmt.Sprintf("Trigger update helmrequest %s error: %s", hr.Name, err.Error())
+			pctx.CreateEvent("Warning", "FailedSync", message, hr)
+			errs = append(errs, err)
+		} else {
+			message := fmt.Sprintf("Trigger update helmrequest %s", hr.Name)
+			pctx.CreateEvent("Normal", "Synced", message, hr)
+
+			if err := opts.printer.PrintObj(synced, pctx.Out()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+
+}
@@ -0,0 +1,249 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/alauda/helm-crds/pkg/apis/app/v1beta1"
+	"github.com/alauda/kubectl-captain/pkg/plugin"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
+	"k8s.io/klog"
+	"os"
+	"strings"
+)
+
+var (
+	updateRepoExample = `
+	# change the url of chartrepo foo and wait for it to be synced
+	kubectl captain update-repo foo -n alauda-system --url=https://charts.example.org -w
+
+	# rotate the password of chartrepo foo, read from stdin
+	cat password.txt | kubectl captain update-repo foo -n alauda-system --password-stdin
+
+	# change the credentials of chartrepo foo, the password is read from $REPO_PASSWORD
+	kubectl captain update-repo foo -n alauda-system --username=tom --password-env=REPO_PASSWORD
+
+	# trust a new CA of chartrepo foo
+	kubectl captain update-repo foo -n alauda-system --ca-file=ca.crt -w
+`
+)
+
+type UpdateRepoOption struct {
+	url string
+
+	username      string
+	password      string
+	passwordStdin bool
+	passwordEnv   string
+	tls           repoTLSFlags
+
+	waitFlags
+
+	printFlags *genericclioptions.PrintFlags
+	printer    printers.ResourcePrinter
+
+	pctx *plugin.CaptainContext
+}
+
+func NewUpdateRepoOption() *UpdateRepoOption {
+	return &UpdateRepoOption{
+		printFlags: newPrintFlags("updated"),
+	}
+}
+
+func NewUpdateRepoCommand() *cobra.Command {
+	opts := NewUpdateRepoOption()
+
+	cmd := &cobra.Command{
+		Use:     "update-repo",
+		Short:   "change the url, credentials or tls settings of a chartrepo and resync it",
+		Example: updateRepoExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := opts.Complete(pctx); err != nil {
+				return err
+			}
+
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+
+			if err := opts.Run(args); err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+
+	opts.waitFlags.AddFlags(cmd, "chartrepo")
+	cmd.Flags().StringVarP(&opts.url, "url", "", "", "new repo url")
+	cmd.Flags().StringVarP(&opts.username, "username", "u", "", "new repo username, the stored one is kept if empty")
+	cmd.Flags().StringVarP(&opts.password, "password", "p", "", "new repo password, the stored one is kept if empty")
+	cmd.Flags().BoolVar(&opts.passwordStdin, "password-stdin", false, "read the new repo password from stdin")
+	cmd.Flags().StringVar(&opts.passwordEnv, "password-env", "", "read the new repo password from this environment variable")
+	opts.tls.AddFlags(cmd)
+	opts.printFlags.AddFlags(cmd)
+	return cmd
+}
+
+func (opts *UpdateRepoOption) Complete(pctx *plugin.CaptainContext) (err error) {
+	opts.pctx = pctx
+	opts.printer, err = opts.printFlags.ToPrinter()
+	return err
+}
+
+func (opts *UpdateRepoOption) Validate() error {
+	sources := 0
+	for _, set := range []bool{opts.password != "", opts.passwordStdin, opts.passwordEnv != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return fmt.Errorf("only one of --password, --password-stdin and --password-env can be used")
+	}
+	return opts.tls.Validate()
+}
+
+// Run update the credentials secret, patch the spec and reset the phase so captain will sync the chartrepo again
+func (opts *UpdateRepoOption) Run(args []string) (err error) {
+	if opts.pctx == nil {
+		klog.Errorf("UpdateRepoOption.ctx should not be nil")
+		return fmt.Errorf("UpdateRepoOption.ctx should not be nil")
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("user should input chartrepo name to update")
+	}
+
+	name := args[0]
+	pctx := opts.pctx
+	namespace := pctx.GetNamespace()
+
+	repo, err := pctx.GetChartRepo(name, namespace)
+	if err != nil {
+		return errors.Wrap(err, "get chartrepo error")
+	}
+
+	password, err := opts.readPassword()
+	if err != nil {
+		return err
+	}
+
+	spec := map[string]interface{}{}
+	urlChanged := opts.url != "" && opts.url != repo.Spec.URL
+	if urlChanged {
+		spec["url"] = opts.url
+	}
+	// for old v1alpha1 data
+	if repo.Spec.Type == "" {
+		spec["type"] = string(v1beta1.ChartRepoChart)
+	}
+
+	creds := plugin.RepoCredentials{Username: opts.username, Password: password}
+	if err := opts.tls.apply(&creds); err != nil {
+		return err
+	}
+
+	// the secret is applied first, so the chartrepo never references a secret that doesn't exist
+	if !creds.IsEmpty() {
+		ref := repo.Spec.Secret
+		if ref == nil {
+			ref = &v1.SecretReference{Name: name, Namespace: namespace}
+			if err := opts.checkAdoptSecret(name); err != nil {
+				return err
+			}
+			spec["secret"] = ref
+		}
+		secretNamespace := ref.Namespace
+		if secretNamespace == "" {
+			secretNamespace = namespace
+		}
+		if _, err := pctx.ApplyRepoSecret(ref.Name, secretNamespace, creds); err != nil {
+			return errors.Wrap(err, "update chartrepo secret error")
+		}
+		pctx.Infof("Updated secret %s/%s", secretNamespace, ref.Name)
+	}
+
+	if len(spec) > 0 {
+		data, err := json.Marshal(map[string]interface{}{"spec": spec})
+		if err != nil {
+			return err
+		}
+		if _, err := pctx.PatchChartRepo(repo.Name, data); err != nil {
+			if urlChanged && isAdmissionDenied(err) {
+				return fmt.Errorf("captain refused to change the url of chartrepo %s: %s, "+
+					"delete and recreate the chartrepo to use a new url", name, err.Error())
+			}
+			return errors.Wrap(err, "update chartrepo error")
+		}
+		if urlChanged {
+			pctx.Infof("Updated url of chartrepo %s to %s", name, opts.url)
+		}
+	}
+
+	patched, err := pctx.PatchChartRepoStatus(repo.Name, []byte(`{"status":{"phase":"Pending"}}`))
+	if err != nil {
+		return errors.Wrap(err, "resync chartrepo error")
+	}
+
+	if !opts.wait {
+		return opts.printer.PrintObj(patched, pctx.Out())
+	}
+
+	synced, err := waitChartRepoSynced(pctx, name, namespace, patched.ResourceVersion, opts.waitFlags)
+	if err != nil {
+		return err
+	}
+	return opts.printer.PrintObj(synced, pctx.Out())
+}
+
+// checkAdoptSecret refuse to point the chartrepo to an existing secret which is not created by this plugin,
+// it may hold other data and would be changed under it's owner
+func (opts *UpdateRepoOption) checkAdoptSecret(name string) error {
+	secret, err := opts.pctx.GetSecret(name)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "get secret error")
+	}
+	if !plugin.IsPluginRepoSecret(secret) {
+		return fmt.Errorf("secret %s/%s already exists and is not created by kubectl-captain, refuse to use it for chartrepo %s",
+			opts.pctx.GetNamespace(), name, name)
+	}
+	return nil
+}
+
+// readPassword returns the password from the flag, stdin or the environment variable
+func (opts *UpdateRepoOption) readPassword() (string, error) {
+	switch {
+	case opts.passwordStdin:
+		data, err := ioutil.ReadAll(opts.pctx.In())
+		if err != nil {
+			return "", errors.Wrap(err, "read password from stdin error")
+		}
+		password := strings.TrimRight(string(data), "\r\n")
+		if password == "" {
+			return "", fmt.Errorf("read an empty password from stdin")
+		}
+		return password, nil
+	case opts.passwordEnv != "":
+		password := os.Getenv(opts.passwordEnv)
+		if password == "" {
+			return "", fmt.Errorf("environment variable %s is empty or not set", opts.passwordEnv)
+		}
+		return password, nil
+	}
+	return opts.password, nil
+}
+
+// isAdmissionDenied tells whether the error comes from captain's webhook rejecting the change
+func isAdmissionDenied(err error) bool {
+	return apierrors.IsInvalid(err) || apierrors.IsForbidden(err) || apierrors.IsBadRequest(err) ||
+		strings.Contains(err.Error(), "denied the request")
+}
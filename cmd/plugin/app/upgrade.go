@@ -1,15 +1,13 @@
 package app
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/alauda/helm-crds/pkg/apis/app/v1alpha1"
-	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"helm.sh/helm/pkg/chartutil"
-	"helm.sh/helm/pkg/strvals"
-	"k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/klog"
 	"strings"
 	"time"
@@ -17,30 +15,69 @@ import (
 	"github.com/alauda/kubectl-captain/pkg/plugin"
 )
 
+// resyncAnnotation is bumped on every change made by this plugin, so captain will always see an update
+const resyncAnnotation = "kubectl-captain.resync"
+
 var (
 	updateExample = `
 	# upgrade helmrequest in default ns to set it's chart version to 1.5.0 and set value 'a=b'
 	kubectl captain upgrade foo -n default -v 1.5.0 --set=a=b
+
+	# upgrade helmrequest to drop a stored value, or to drop all of them and use the given ones only
+	kubectl captain upgrade foo -n default --unset=image.tag
+	kubectl captain upgrade foo -n default --reset-values -f values.yaml
+
+	# upgrade helmrequest to obtain values from a secret too
+	kubectl captain upgrade foo -n default --values-from=secret:foo-creds:values.yaml
+
+	# preview what an upgrade would change
+	kubectl captain upgrade foo -n default -v 1.5.0 --set=a=b --dry-run
+
+	# upgrade helmrequest and rollback to the previous spec if it's not synced in 5 minutes
+	kubectl captain upgrade foo -n default -v 1.5.0 --atomic --timeout=300
 `
 )
 
 type UpgradeOption struct {
 	version string
-	values  []string
+	values  plugin.ValueOptions
+
+	// resetValues start from empty values instead of the stored ones
+	resetValues bool
+	// unset are the keys to delete from the stored values
+	unset []string
 
-	wait    bool
-	timeout int
+	waitFlags
+	// noNotes skip the chart notes printed after a successful wait
+	noNotes bool
+
+	// atomic rollback to the previous spec if the upgrade failed
+	atomic bool
 
 	// maybe the user what to use a different repo
 	repo string
 
+	valuesFrom plugin.ValuesFromOptions
+	// cm is the deprecated --configmap, same as --values-from=configmap:<cm>
 	cm string
 
+	// dryRun print the diff instead of upgrading
+	dryRun bool
+	diff   diffPrinter
+
+	// command is recorded in the revision history
+	command string
+
+	printFlags *genericclioptions.PrintFlags
+	printer    printers.ResourcePrinter
+
 	pctx *plugin.CaptainContext
 }
 
 func NewUpdateOption() *UpgradeOption {
-	return &UpgradeOption{}
+	return &UpgradeOption{
+		printFlags: newPrintFlags("upgraded"),
+	}
 }
 
 func NewUpgradeCommand() *cobra.Command {
@@ -51,6 +88,7 @@ func NewUpgradeCommand() *cobra.Command {
 		Short:   "upgrade a helmrequest",
 		Example: updateExample,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.command = commandLine(cmd, args)
 			if err := opts.Complete(pctx); err != nil {
 				return err
 			}
@@ -66,18 +104,35 @@ func NewUpgradeCommand() *cobra.Command {
 		},
 	}
 
-	cmd.Flags().StringArrayVarP(&opts.values, "set", "s", []string{}, "custom values")
+	opts.addSpecFlags(cmd)
+	opts.waitFlags.AddFlags(cmd, "helmrequest")
+	addNotesFlag(cmd, &opts.noNotes)
+	cmd.Flags().BoolVar(&opts.atomic, "atomic", false, "rollback to the previous spec if the upgrade failed, implies --wait")
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "print the changes the upgrade would make instead of upgrading")
+	cmd.Flags().BoolVar(&opts.diff.manifest, "manifest", false, "with --dry-run, also diff the manifest rendered by the chart of the deployed release")
+	opts.printFlags.AddFlags(cmd)
+	return cmd
+}
+
+// addSpecFlags add the flags that change the spec, shared by upgrade and diff
+func (opts *UpgradeOption) addSpecFlags(cmd *cobra.Command) {
+	addValueFlags(cmd, &opts.values)
+	cmd.Flags().BoolVar(&opts.resetValues, "reset-values", false, "reset the values to the ones given on the command line, the stored values are dropped")
+	cmd.Flags().StringArrayVar(&opts.unset, "unset", []string{}, "delete a key from the stored values, eg: --unset=image.tag (can specify multiple)")
 	cmd.Flags().StringVarP(&opts.version, "version", "v", "", "the chart version you want to use ")
-	cmd.Flags().BoolVarP(&opts.wait, "wait", "w", false, "wait for the helmrequest to be synced")
-	cmd.Flags().IntVarP(&opts.timeout, "timeout", "t", 0, "timeout for the wait")
 	cmd.Flags().StringVarP(&opts.repo, "repo", "r", "", "chartrepo for the chart")
-	cmd.Flags().StringVarP(&opts.cm, "configmap", "", "", "configmap to obtain values from, it must contains a key called 'values.yaml'")
-	return cmd
+	addValuesFromFlags(cmd, &opts.valuesFrom)
+	cmd.Flags().StringVarP(&opts.cm, "configmap", "", "", "configmap to obtain values from instead of the existing sources, it must contains a key called 'values.yaml'")
+	_ = cmd.Flags().MarkDeprecated("configmap", "use --values-from=configmap:<name> instead")
 }
 
-func (opts *UpgradeOption) Complete(pctx *plugin.CaptainContext) error {
+func (opts *UpgradeOption) Complete(pctx *plugin.CaptainContext) (err error) {
 	opts.pctx = pctx
-	return nil
+	if opts.atomic {
+		opts.wait = true
+	}
+	opts.printer, err = opts.printFlags.ToPrinter()
+	return err
 }
 
 func (opts *UpgradeOption) Validate() error {
@@ -85,7 +140,7 @@ func (opts *UpgradeOption) Validate() error {
 }
 
 // Run do the real update
-// 1. save the old spec to annotation
+// 1. save the new spec to the revision history
 // 2. update
 func (opts *UpgradeOption) Run(args []string) (err error) {
 	if opts.pctx == nil {
@@ -103,17 +158,63 @@ func (opts *UpgradeOption) Run(args []string) (err error) {
 		return err
 	}
 
-	// TODO: remove
-	old, err := json.Marshal(hr.Spec)
+	previous := hr.Spec.DeepCopy()
+
+	if hr.Annotations == nil {
+		hr.Annotations = make(map[string]string)
+	}
+	hr.Annotations[resyncAnnotation] = time.Now().String()
+
+	if err := opts.applySpec(hr); err != nil {
+		return err
+	}
+
+	if opts.dryRun {
+		return opts.diff.print(pctx, previous, hr)
+	}
+
+	if err := plugin.RecordRevision(hr, previous, pctx.GetUser(), opts.command, plugin.DefaultHistoryMax); err != nil {
+		return err
+	}
+
+	updated, err := pctx.UpdateHelmRequest(hr)
 	if err != nil {
 		return err
 	}
+	if !opts.wait {
+		return opts.printer.PrintObj(updated, pctx.Out())
+	}
 
-	if hr.Annotations == nil {
-		hr.Annotations = make(map[string]string)
+	// the phase left from before the upgrade is not the result of it, reset it so captain writes a new one
+	if updated, err = pctx.ResyncHelmRequest(updated.Name); err != nil {
+		return err
+	}
+	synced, err := waitHelmRequestSynced(pctx, updated, opts.waitFlags)
+	if err != nil {
+		message := fmt.Sprintf("Updated helmrequest %s error with version: %s values: %+v, err: %s", hr.Name, opts.version, opts.values.Values, err.Error())
+		pctx.CreateEvent("Warning", "FailedSync", message, hr)
+		if opts.atomic {
+			return opts.rollback(updated, previous, err)
+		}
+	} else {
+		message := fmt.Sprintf("Updated helmrequest %s with version: %s values: %+v", hr.Name, opts.version, opts.values.Values)
+		pctx.CreateEvent("Normal", "Synced", message, hr)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := opts.printer.PrintObj(synced, pctx.Out()); err != nil {
+		return err
 	}
-	hr.Annotations["last-spec"] = string(old)
-	hr.Annotations["kubectl-captain.resync"] = time.Now().String()
+	printSyncedNotes(pctx, synced, opts.noNotes, opts.printFlags)
+	return nil
+
+}
+
+// applySpec change the spec of hr by the flags, it's all what upgrade changes besides the annotations
+func (opts *UpgradeOption) applySpec(hr *v1alpha1.HelmRequest) error {
+	pctx := opts.pctx
 
 	if opts.version != "" {
 		hr.Spec.Version = opts.version
@@ -121,94 +222,88 @@ func (opts *UpgradeOption) Run(args []string) (err error) {
 
 	if opts.repo != "" {
 		splits := strings.Split(hr.Spec.Chart, "/")
+		if len(splits) != 2 {
+			return fmt.Errorf("chart %s of helmrequest %s is not in the format of <repo>/<chart>, can not change it's repo", hr.Spec.Chart, hr.Name)
+		}
 		hr.Spec.Chart = opts.repo + "/" + splits[1]
 	}
 
-	// check configmap first
+	valuesFrom := opts.valuesFrom
 	if opts.cm != "" {
-		_, err := pctx.GetConfigMap(opts.cm)
-		if err != nil {
-			return errors.Wrap(err, "ref configmap not eixst")
-		}
-
-		optional := false
-
-		hr.Spec.ValuesFrom = []v1alpha1.ValuesFromSource{
-			{
-				ConfigMapKeyRef: &v1.ConfigMapKeySelector{
-					LocalObjectReference: v1.LocalObjectReference{Name: opts.cm},
-					Key:                  "values.yaml",
-					Optional:             &optional,
-				},
-			},
-		}
+		// the deprecated --configmap replaces the existing sources as it always did
+		valuesFrom.Add = append([]string{"configmap:" + opts.cm}, valuesFrom.Add...)
+		valuesFrom.Replace = true
+	}
+	if err := applyValuesFrom(pctx, hr, &valuesFrom); err != nil {
+		return err
 	}
 
-	// merge values....oh,we have to import helm now....
-	base := hr.Spec.Values.AsMap()
-	for _, value := range opts.values {
-		if err := strvals.ParseInto(value, base); err != nil {
-			return errors.Wrap(err, "failed parsing --set data")
+	stored := hr.Spec.Values.AsMap()
+	if opts.resetValues {
+		stored = map[string]interface{}{}
+	}
+	for _, key := range opts.unset {
+		if !plugin.UnsetValue(stored, key) {
+			pctx.Warningf("key %s not found in the values of helmrequest %s", key, hr.Name)
 		}
 	}
 
-	hr.Spec.Values = chartutil.Values(base)
-
-	_, err = pctx.UpdateHelmRequest(hr)
-	if !opts.wait {
+	// merge values....oh,we have to import helm now....
+	base, err := opts.values.MergeValues(stored, pctx.In())
+	if err != nil {
 		return err
 	}
+	hr.Spec.Values = chartutil.Values(base)
+	return nil
+}
 
-	klog.Info("Start wait for helmrequest to be synced")
+// rollback restore the spec before a failed upgrade and wait for it, the returned error always
+// describe the failed upgrade
+func (opts *UpgradeOption) rollback(upgraded *v1alpha1.HelmRequest, previous *v1alpha1.HelmRequestSpec, upgradeErr error) error {
+	pctx := opts.pctx
+	name := upgraded.Name
+	pctx.Infof("Upgrade failed, rollback helmrequest %s to version: %s", name, previous.Version)
 
-	// For some unknown reasons, the desired chart version may not be synced at this time. So this step
-	// may fail for not found the target chart version. We don't want to report this error directly, as Captain
-	// will retry in the background and it will succeed mostly. So we add this errCount to act as some mechanism.
-	// This should consider a temporary solution.
-	errCount := 0
+	failed := func(err error) error {
+		message := fmt.Sprintf("Rollback helmrequest %s to version %s after failed upgrade error: %s", name, previous.Version, err.Error())
+		pctx.CreateEvent("Warning", "FailedRollback", message, upgraded)
+		return fmt.Errorf("upgrade failed: %s, and rollback failed: %s", upgradeErr.Error(), err.Error())
+	}
 
-	f := func() (done bool, err error) {
-		result, err := pctx.GetHelmRequest(hr.GetName())
+	// captain keeps writing the status of the helmrequest, so the update may conflict with it
+	var updated *v1alpha1.HelmRequest
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		hr, err := pctx.GetHelmRequest(name)
 		if err != nil {
-			return false, err
+			return err
 		}
 
-		if result.Status.Phase == "Failed" && errCount > 75 {
-			msg, err := pctx.GetEventsMessage(hr)
-			if err != nil {
-				klog.Error("get events for hr error:", err.Error())
-			} else {
-				klog.Info("helmrequest failed, events are: ", msg)
-			}
-			return false, errors.New("helmrequest failed")
+		current := hr.Spec.DeepCopy()
+		hr.Spec = *previous
+		if hr.Annotations == nil {
+			hr.Annotations = make(map[string]string)
 		}
+		hr.Annotations[resyncAnnotation] = time.Now().String()
 
-		if result.Status.Phase == "Failed" {
-			errCount += 1
-			return false, nil
+		if err := plugin.RecordRevision(hr, current, pctx.GetUser(), opts.command+" (atomic rollback)", plugin.DefaultHistoryMax); err != nil {
+			return err
 		}
 
-		return result.Status.Phase == "Synced", nil
-	}
-
-	if opts.timeout != 0 {
-		err = wait.Poll(1*time.Second, time.Duration(opts.timeout)*time.Second, f)
-	} else {
-		err = wait.PollInfinite(1*time.Second, f)
+		updated, err = pctx.UpdateHelmRequest(hr)
+		return err
+	})
+	if err != nil {
+		return failed(err)
 	}
 
-	if errCount > 0 {
-		klog.Warning("Retried failed helmrequest...")
+	if updated, err = pctx.ResyncHelmRequest(name); err != nil {
+		return failed(err)
 	}
-
-	if err != nil {
-		message := fmt.Sprintf("Updated helmrequest %s error with version: %s values: %+v, err: %s", hr.Name, opts.version, opts.values, err.Error())
-		pctx.CreateEvent("Warning", "FailedSync", message, hr)
-	} else {
-		message := fmt.Sprintf("Updated helmrequest %s with version: %s values: %+v", hr.Name, opts.version, opts.values)
-		pctx.CreateEvent("Normal", "Synced", message, hr)
+	if _, err := waitHelmRequestSynced(pctx, updated, opts.waitFlags); err != nil {
+		return failed(err)
 	}
 
-	return err
-
+	message := fmt.Sprintf("Rollback helmrequest %s to version %s after failed upgrade", name, previous.Version)
+	pctx.CreateEvent("Normal", "RolledBack", message, updated)
+	return fmt.Errorf("upgrade failed and has been rolled back to version %s: %s", previous.Version, upgradeErr.Error())
 }
@@ -0,0 +1,17 @@
+package app
+
+import (
+	"github.com/alauda/helm-crds/pkg/apis/app/v1alpha1"
+	"github.com/gsamokovarov/assert"
+	"testing"
+)
+
+func TestApplySpecRepo(t *testing.T) {
+	opts := NewUpdateOption()
+	opts.repo = "stable"
+
+	var hr v1alpha1.HelmRequest
+	hr.Name = "foo"
+	hr.Spec.Chart = "nginx"
+	assert.NotNil(t, opts.applySpec(&hr))
+}
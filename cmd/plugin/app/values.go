@@ -0,0 +1,41 @@
+package app
+
+import (
+	"github.com/alauda/helm-crds/pkg/apis/app/v1alpha1"
+	"github.com/alauda/kubectl-captain/pkg/plugin"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// addValueFlags add the flags to set values, shared by create and upgrade
+func addValueFlags(cmd *cobra.Command, v *plugin.ValueOptions) {
+	cmd.Flags().StringArrayVarP(&v.ValueFiles, "values", "f", []string{}, "specify values in a YAML file, '-' means stdin (can specify multiple)")
+	cmd.Flags().StringArrayVarP(&v.Values, "set", "s", []string{}, "set values on the command line (can specify multiple or separate values with commas: key1=val1,key2=val2)")
+	cmd.Flags().StringArrayVar(&v.StringValues, "set-string", []string{}, "set STRING values on the command line (can specify multiple or separate values with commas: key1=val1,key2=val2)")
+	cmd.Flags().StringArrayVar(&v.FileValues, "set-file", []string{}, "set values from respective files specified via the command line (can specify multiple: key1=path1 --set-file key2=path2)")
+}
+
+// addValuesFromFlags add the flags to change the ValuesFrom of a helmrequest, shared by create and upgrade
+func addValuesFromFlags(cmd *cobra.Command, o *plugin.ValuesFromOptions) {
+	cmd.Flags().StringArrayVar(&o.Add, "values-from", []string{}, "obtain values from a configmap or secret, format: configmap|secret:<name>[:key], key defaults to 'values.yaml' (can specify multiple)")
+	cmd.Flags().StringArrayVar(&o.Remove, "remove-values-from", []string{}, "remove a values source, format: configmap|secret:<name>[:key], all keys of the object are removed if key is omitted (can specify multiple)")
+	cmd.Flags().BoolVar(&o.Replace, "replace-values-from", false, "replace all the existing values sources with the ones given by --values-from")
+	cmd.Flags().BoolVar(&o.Optional, "optional", false, "mark the values sources given by --values-from as optional, captain ignores them if they don't exist")
+}
+
+// applyValuesFrom change the ValuesFrom of hr, the added sources are verified before the helmrequest is written
+func applyValuesFrom(pctx *plugin.CaptainContext, hr *v1alpha1.HelmRequest, o *plugin.ValuesFromOptions) error {
+	sources, added, err := o.Apply(hr.Spec.ValuesFrom)
+	if err != nil {
+		return err
+	}
+
+	for _, source := range added {
+		if err := pctx.CheckValuesFrom(source); err != nil {
+			return errors.Wrap(err, "check values-from error")
+		}
+	}
+
+	hr.Spec.ValuesFrom = sources
+	return nil
+}
@@ -13,7 +13,7 @@ func NewVersionCommand() *cobra.Command {
 		Use:   "version",
 		Short: "Print the version number of kubectl-captain",
 		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Println("kubectl-captain: " + version)
+			fmt.Fprintln(cmd.OutOrStdout(), "kubectl-captain: "+version)
 		},
 	}
 	return versionCmd
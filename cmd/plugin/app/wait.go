@@ -0,0 +1,145 @@
+package app
+
+import (
+	"fmt"
+	"github.com/alauda/helm-crds/pkg/apis/app/v1alpha1"
+	"github.com/alauda/helm-crds/pkg/apis/app/v1beta1"
+	"github.com/alauda/kubectl-captain/pkg/plugin"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/runtime"
+	"text/tabwriter"
+	"time"
+)
+
+// For some unknown reasons, the desired chart version may not be synced at this time. So a sync
+// may fail for not found the target chart version. We don't want to report this error directly, as Captain
+// will retry in the background and it will succeed mostly. So we tolerate the Failed phase for a while.
+const defaultFailureGrace = 75 * time.Second
+
+// recentEvents is how many events are printed when a wait fails
+const recentEvents = 5
+
+// waitFlags are the flags shared by every command that can wait for the object it wrote
+type waitFlags struct {
+	wait         bool
+	timeout      int
+	failureGrace time.Duration
+
+	// deadline is shared by the waits of a command, see withDeadline
+	deadline time.Time
+}
+
+func (f *waitFlags) AddFlags(cmd *cobra.Command, kind string) {
+	cmd.Flags().BoolVarP(&f.wait, "wait", "w", false, fmt.Sprintf("wait for the %s to be synced", kind))
+	cmd.Flags().IntVarP(&f.timeout, "timeout", "t", 0, "timeout for the wait in seconds, 0 means wait forever")
+	cmd.Flags().DurationVar(&f.failureGrace, "failure-grace", defaultFailureGrace,
+		fmt.Sprintf("how long the %s may stay Failed before the wait fails, captain retries failed syncs in the background", kind))
+}
+
+// withDeadline returns a copy of f whose waits end by --timeout from now all together, so waiting for
+// several objects one by one doesn't take longer than --timeout
+func (f waitFlags) withDeadline() waitFlags {
+	if f.timeout > 0 {
+		f.deadline = time.Now().Add(time.Duration(f.timeout) * time.Second)
+	}
+	return f
+}
+
+func (f *waitFlags) options(resourceVersion string) plugin.WaitOptions {
+	timeout := time.Duration(f.timeout) * time.Second
+	if !f.deadline.IsZero() {
+		// 0 means wait forever, so time out at once when the deadline has passed
+		if timeout = time.Until(f.deadline); timeout <= 0 {
+			timeout = time.Nanosecond
+		}
+	}
+	return plugin.WaitOptions{
+		Timeout:         timeout,
+		FailureGrace:    f.failureGrace,
+		ResourceVersion: resourceVersion,
+	}
+}
+
+// waitHelmRequestSynced wait for the helmrequest we just wrote to be synced
+func waitHelmRequestSynced(pctx *plugin.CaptainContext, hr *v1alpha1.HelmRequest, flags waitFlags) (*v1alpha1.HelmRequest, error) {
+	pctx.Infof("Start wait for helmrequest %s to be synced", hr.Name)
+
+	result, err := pctx.WaitHelmRequest(hr.Name, flags.options(hr.ResourceVersion), plugin.HelmRequestSynced)
+	if err != nil {
+		var obj runtime.Object = hr
+		if result != nil {
+			obj = result
+		}
+		reason := printWaitFailure(pctx, obj, "")
+		if reason != "" {
+			return result, fmt.Errorf("helmrequest %s: %s: %s", hr.Name, err.Error(), reason)
+		}
+		return result, fmt.Errorf("helmrequest %s: %s", hr.Name, err.Error())
+	}
+	return result, nil
+}
+
+// waitHelmRequestDeleted wait for the helmrequest we just deleted to be gone, which means captain has
+// uninstalled it's release. hr should be the one read right after the delete.
+func waitHelmRequestDeleted(pctx *plugin.CaptainContext, hr *v1alpha1.HelmRequest, flags waitFlags) error {
+	pctx.Infof("Start wait for helmrequest %s to be uninstalled", hr.Name)
+
+	result, err := pctx.WaitHelmRequest(hr.Name, flags.options(hr.ResourceVersion), plugin.HelmRequestDeleted(hr.ResourceVersion))
+	if err != nil {
+		var obj runtime.Object = hr
+		if result != nil {
+			obj = result
+		}
+		reason := printWaitFailure(pctx, obj, "")
+		if reason != "" {
+			return fmt.Errorf("helmrequest %s: %s: %s", hr.Name, err.Error(), reason)
+		}
+		return fmt.Errorf("helmrequest %s: %s", hr.Name, err.Error())
+	}
+	return nil
+}
+
+// waitChartRepoSynced wait for the chartrepo we just wrote to be synced
+func waitChartRepoSynced(pctx *plugin.CaptainContext, name, namespace, resourceVersion string, flags waitFlags) (*v1beta1.ChartRepo, error) {
+	pctx.Infof("Start wait for chartrepo %s to be synced", name)
+
+	result, err := pctx.WaitChartRepo(name, namespace, flags.options(resourceVersion), plugin.ChartRepoSynced)
+	if err != nil {
+		obj, reason := result, ""
+		if obj != nil {
+			reason = obj.Status.Reason
+		} else {
+			obj = &v1beta1.ChartRepo{}
+			obj.Name, obj.Namespace = name, namespace
+		}
+		reason = printWaitFailure(pctx, obj, reason)
+		if reason != "" {
+			return result, fmt.Errorf("chartrepo %s: %s: %s", name, err.Error(), reason)
+		}
+		return result, fmt.Errorf("chartrepo %s: %s", name, err.Error())
+	}
+	return result, nil
+}
+
+// printWaitFailure print the recent events of obj to stderr and returns the failure reason, which is
+// reason if it's not empty, or the latest Warning event
+func printWaitFailure(pctx *plugin.CaptainContext, obj runtime.Object, reason string) string {
+	events, err := pctx.GetEvents(obj)
+	if err != nil {
+		pctx.Warningf("get events error: %s", err.Error())
+		return reason
+	}
+
+	if reason == "" {
+		reason = plugin.FailureReason(events)
+	}
+	if len(events) > recentEvents {
+		events = events[len(events)-recentEvents:]
+	}
+
+	w := tabwriter.NewWriter(pctx.ErrOut(), 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "Recent events:")
+	printEvents(w, events)
+	_ = w.Flush()
+	return reason
+}
@@ -0,0 +1,24 @@
+package app
+
+import (
+	"github.com/gsamokovarov/assert"
+	"testing"
+	"time"
+)
+
+func TestWaitFlagsWithDeadline(t *testing.T) {
+	flags := waitFlags{timeout: 60}
+	assert.Equal(t, time.Minute, flags.options("").Timeout)
+
+	shared := flags.withDeadline()
+	timeout := shared.options("").Timeout
+	assert.True(t, timeout > 0 && timeout <= time.Minute)
+
+	// a passed deadline times out at once instead of waiting forever
+	shared.deadline = time.Now().Add(-time.Second)
+	assert.Equal(t, time.Nanosecond, shared.options("").Timeout)
+
+	// no timeout, no deadline
+	forever := waitFlags{}.withDeadline()
+	assert.Equal(t, time.Duration(0), forever.options("").Timeout)
+}
@@ -7,14 +7,21 @@ replace k8s.io/client-go => k8s.io/client-go v0.0.0-20190620085101-78d2af792bab
 replace github.com/alauda/helm-crds => github.com/alauda/helm-crds v0.0.0-20200311033314-5e41368b07e2
 
 require (
+	github.com/Masterminds/goutils v1.1.0 // indirect
+	github.com/Masterminds/sprig v2.20.0+incompatible // indirect
 	github.com/alauda/helm-crds v0.0.0-20190904040405-5d13ef317cd8
 	github.com/ghodss/yaml v1.0.0
+	github.com/gobwas/glob v0.2.3 // indirect
 	github.com/google/btree v0.0.0-20180813153112-4030bb1f1f0c // indirect
 	github.com/gregjones/httpcache v0.0.0-20190611155906-901d90724c79 // indirect
 	github.com/gsamokovarov/assert v0.0.0-20180414063448-8cd8ab63a335
+	github.com/huandu/xstrings v1.2.0 // indirect
 	github.com/imdario/mergo v0.3.7 // indirect
+	github.com/mitchellh/copystructure v1.0.0 // indirect
 	github.com/pkg/errors v0.8.1
+	github.com/pmezard/go-difflib v1.0.0
 	github.com/spf13/cobra v0.0.5
+	github.com/spf13/pflag v1.0.3
 	github.com/teris-io/shortid v0.0.0-20160104014424-6c56cef5189c
 	github.com/ventu-io/go-shortid v0.0.0-20171029131806-771a37caa5cf // indirect
 	helm.sh/helm v3.0.0-alpha.1.0.20190613170622-c35dbb7aabf8+incompatible
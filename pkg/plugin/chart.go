@@ -0,0 +1,214 @@
+package plugin
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/alauda/helm-crds/pkg/apis/app/v1alpha1"
+	"github.com/alauda/helm-crds/pkg/apis/app/v1beta1"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"helm.sh/helm/pkg/chart"
+	"helm.sh/helm/pkg/chart/loader"
+	"helm.sh/helm/pkg/repo"
+)
+
+// downloadTimeout is the timeout of a single request to a chart repository
+const downloadTimeout = 60 * time.Second
+
+// repoClient download files from a chart repository with it's credentials. The index may point the charts
+// to any host, so the credentials and the client certificate are only sent to the scheme and host of the
+// repository itself.
+type repoClient struct {
+	repo *url.URL
+	// client carries the tls settings of the repository, public is used for the other hosts
+	client *http.Client
+	public *http.Client
+
+	username string
+	password string
+}
+
+// sameOrigin tells whether u has the same scheme and host as the repository
+func (c *repoClient) sameOrigin(u *url.URL) bool {
+	return strings.EqualFold(u.Scheme, c.repo.Scheme) && strings.EqualFold(u.Host, c.repo.Host)
+}
+
+func (c *repoClient) get(u string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	client := c.public
+	if c.sameOrigin(req.URL) {
+		client = c.client
+		if c.username != "" || c.password != "" {
+			req.SetBasicAuth(c.username, c.password)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get %s error: %s", u, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// newRepoClient returns a client for the chartrepo, the credentials and tls settings are read from it's secret
+func (p *CaptainContext) newRepoClient(cr *v1beta1.ChartRepo) (*repoClient, error) {
+	u, err := url.Parse(cr.Spec.URL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid url of chartrepo %s", cr.Name)
+	}
+	c := &repoClient{
+		repo:   u,
+		client: &http.Client{Timeout: downloadTimeout},
+		public: &http.Client{Timeout: downloadTimeout},
+	}
+
+	if cr.Spec.Secret != nil {
+		secret, err := p.GetRepoSecret(cr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get secret of chartrepo %s error", cr.Name)
+		}
+		c.username = string(secret.Data[RepoUsernameKey])
+		c.password = string(secret.Data[RepoPasswordKey])
+
+		config, err := repoTLSConfig(secret)
+		if err != nil {
+			return nil, errors.Wrapf(err, "load tls config of chartrepo %s error", cr.Name)
+		}
+		if config != nil {
+			// keep the proxy, timeouts and keep-alive settings of the default transport
+			transport := http.DefaultTransport.(*http.Transport).Clone()
+			transport.TLSClientConfig = config
+			c.client.Transport = transport
+		}
+	}
+	return c, nil
+}
+
+// LoadChart download the chart from it's chartrepo in repoNamespace. name is in the format of <repo>/<chart>,
+// the latest version is used if version is empty.
+func (p *CaptainContext) LoadChart(name, version, repoNamespace string) (*chart.Chart, error) {
+	splits := strings.Split(name, "/")
+	if len(splits) != 2 {
+		return nil, fmt.Errorf("invalid chart %q, the format is <repo>/<chart>", name)
+	}
+	repoName, chartName := splits[0], splits[1]
+
+	cr, err := p.GetChartRepo(repoName, repoNamespace)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get chartrepo %s error", repoName)
+	}
+
+	client, err := p.newRepoClient(cr)
+	if err != nil {
+		return nil, err
+	}
+
+	base := strings.TrimSuffix(cr.Spec.URL, "/") + "/"
+	data, err := client.get(base + "index.yaml")
+	if err != nil {
+		return nil, errors.Wrapf(err, "get index of chartrepo %s error", repoName)
+	}
+
+	var index repo.IndexFile
+	if err := yaml.Unmarshal(data, &index); err != nil {
+		return nil, errors.Wrapf(err, "parse index of chartrepo %s error", repoName)
+	}
+	index.SortEntries()
+
+	cv, err := index.Get(chartName, version)
+	if err != nil {
+		return nil, errors.Wrapf(err, "chart %s version %q not found", name, version)
+	}
+	if len(cv.URLs) == 0 {
+		return nil, fmt.Errorf("chart %s version %s has no download url", name, cv.Version)
+	}
+
+	// urls in the index may be relative to the repository
+	u, err := resolveURL(base, cv.URLs[0])
+	if err != nil {
+		return nil, err
+	}
+	data, err = client.get(u)
+	if err != nil {
+		return nil, errors.Wrapf(err, "download chart %s error", name)
+	}
+	return loader.LoadArchive(bytes.NewReader(data))
+}
+
+func resolveURL(base, ref string) (string, error) {
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	return b.ResolveReference(r).String(), nil
+}
+
+// RepoDependants returns the helmrequests in all namespaces whose chart comes from the repo, the chart
+// of a helmrequest is in the format of <repo>/<chart>. The chart only names the repo, so the match is by
+// name only and chartrepos with the same name in different namespaces can't be told apart.
+func (p *CaptainContext) RepoDependants(repo string) ([]v1alpha1.HelmRequest, error) {
+	list, err := p.ListHelmRequests("", "")
+	if err != nil {
+		return nil, err
+	}
+
+	var result []v1alpha1.HelmRequest
+	for _, hr := range list.Items {
+		if strings.HasPrefix(hr.Spec.Chart, repo+"/") {
+			result = append(result, hr)
+		}
+	}
+	return result, nil
+}
+
+// repoTypes map the types users give to the ChartRepo types
+var repoTypes = map[string]v1beta1.ChartRepoType{
+	"chart": v1beta1.ChartRepoChart,
+	"git":   v1beta1.ChartRepoGit,
+	"svn":   v1beta1.ChartRepoSvn,
+}
+
+// ParseRepoType returns the ChartRepo type of chart, git or svn, case insensitive
+func ParseRepoType(s string) (v1beta1.ChartRepoType, error) {
+	repoType, ok := repoTypes[strings.ToLower(s)]
+	if !ok {
+		return "", fmt.Errorf("unknown repo type %q, should be one of: chart, git, svn", s)
+	}
+	return repoType, nil
+}
+
+// NewChartRepo returns a chartrepo of url. For git and svn repos, url is the vcs url and the charts are
+// in path of it's default branch, the ChartRepo has no field for the branch.
+func NewChartRepo(name, namespace string, repoType v1beta1.ChartRepoType, url, path string) *v1beta1.ChartRepo {
+	var cr v1beta1.ChartRepo
+	cr.Name = name
+	cr.Namespace = namespace
+	cr.Spec.URL = url
+	cr.Spec.Type = string(repoType)
+	if repoType != v1beta1.ChartRepoChart {
+		cr.Spec.Source = &v1beta1.ChartRepoSource{
+			URL:  url,
+			Path: path,
+		}
+	}
+	return &cr
+}
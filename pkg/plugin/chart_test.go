@@ -0,0 +1,164 @@
+package plugin
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/alauda/helm-crds/pkg/apis/app/v1alpha1"
+	"github.com/alauda/helm-crds/pkg/apis/app/v1beta1"
+	crdfake "github.com/alauda/helm-crds/pkg/client/clientset/versioned/fake"
+	"github.com/gsamokovarov/assert"
+	"helm.sh/helm/pkg/chartutil"
+	"helm.sh/helm/pkg/repo"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLoadChart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "charts")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	archive, err := chartutil.Save(newTestChart(), dir)
+	assert.Nil(t, err)
+	index, err := repo.IndexDirectory(dir, "")
+	assert.Nil(t, err)
+	assert.Nil(t, index.WriteFile(filepath.Join(dir, "index.yaml"), 0644))
+
+	files := http.FileServer(http.Dir(dir))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		files.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	cr := &v1beta1.ChartRepo{
+		ObjectMeta: metav1.ObjectMeta{Name: "stable", Namespace: "alauda-system"},
+		Spec: v1beta1.ChartRepoSpec{
+			URL:    server.URL,
+			Secret: &v1.SecretReference{Name: "stable"},
+		},
+	}
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "stable", Namespace: "alauda-system"},
+		Data:       map[string][]byte{"username": []byte("admin"), "password": []byte("secret")},
+	}
+	p := &CaptainContext{cli: crdfake.NewSimpleClientset(cr), core: fake.NewSimpleClientset(secret)}
+
+	ch, err := p.LoadChart("stable/demo", "0.1.0", "alauda-system")
+	assert.Nil(t, err)
+	assert.Equal(t, "demo", ch.Name())
+	assert.Equal(t, filepath.Base(archive), "demo-0.1.0.tgz")
+
+	_, err = p.LoadChart("stable/demo", "0.2.0", "alauda-system")
+	assert.NotNil(t, err)
+
+	_, err = p.LoadChart("demo", "", "alauda-system")
+	assert.NotNil(t, err)
+}
+
+func TestLoadChartOtherHost(t *testing.T) {
+	dir, err := ioutil.TempDir("", "charts")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	chartsDir, repoDir := filepath.Join(dir, "charts"), filepath.Join(dir, "repo")
+	assert.Nil(t, os.MkdirAll(chartsDir, 0755))
+	assert.Nil(t, os.MkdirAll(repoDir, 0755))
+
+	_, err = chartutil.Save(newTestChart(), chartsDir)
+	assert.Nil(t, err)
+
+	// the charts are served by another host, which must not see the credentials
+	var authorization []string
+	charts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = append(authorization, r.Header.Get("Authorization"))
+		http.FileServer(http.Dir(chartsDir)).ServeHTTP(w, r)
+	}))
+	defer charts.Close()
+
+	index, err := repo.IndexDirectory(chartsDir, charts.URL)
+	assert.Nil(t, err)
+	assert.Nil(t, index.WriteFile(filepath.Join(repoDir, "index.yaml"), 0644))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.FileServer(http.Dir(repoDir)).ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	cr := &v1beta1.ChartRepo{
+		ObjectMeta: metav1.ObjectMeta{Name: "stable", Namespace: "alauda-system"},
+		Spec: v1beta1.ChartRepoSpec{
+			URL:    server.URL,
+			Secret: &v1.SecretReference{Name: "stable"},
+		},
+	}
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "stable", Namespace: "alauda-system"},
+		Data:       map[string][]byte{"username": []byte("admin"), "password": []byte("secret")},
+	}
+	p := &CaptainContext{cli: crdfake.NewSimpleClientset(cr), core: fake.NewSimpleClientset(secret)}
+
+	ch, err := p.LoadChart("stable/demo", "0.1.0", "alauda-system")
+	assert.Nil(t, err)
+	assert.Equal(t, "demo", ch.Name())
+	assert.Equal(t, []string{""}, authorization)
+}
+
+func TestRepoDependants(t *testing.T) {
+	newHR := func(namespace, name, chart string) *v1alpha1.HelmRequest {
+		hr := &v1alpha1.HelmRequest{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+		hr.Spec.Chart = chart
+		return hr
+	}
+	p := &CaptainContext{cli: crdfake.NewSimpleClientset(
+		newHR("default", "foo", "stable/nginx"),
+		newHR("prod", "bar", "stable/redis"),
+		newHR("default", "baz", "stable-mirror/nginx"),
+	)}
+
+	dependants, err := p.RepoDependants("stable")
+	assert.Nil(t, err)
+	var names []string
+	for _, hr := range dependants {
+		names = append(names, hr.Namespace+"/"+hr.Name)
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"default/foo", "prod/bar"}, names)
+
+	dependants, err = p.RepoDependants("incubator")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(dependants))
+}
+
+func TestNewChartRepo(t *testing.T) {
+	_, err := ParseRepoType("helm")
+	assert.NotNil(t, err)
+
+	repoType, err := ParseRepoType("chart")
+	assert.Nil(t, err)
+	cr := NewChartRepo("stable", "alauda-system", repoType, "https://charts.example.org", "")
+	assert.Equal(t, "Chart", cr.Spec.Type)
+	assert.Equal(t, "https://charts.example.org", cr.Spec.URL)
+	assert.Nil(t, cr.Spec.Source)
+
+	for _, s := range []string{"git", "SVN"} {
+		repoType, err := ParseRepoType(s)
+		assert.Nil(t, err)
+		cr := NewChartRepo("stable", "alauda-system", repoType, "https://vcs.example.org/charts", "charts/")
+		assert.Equal(t, string(repoType), cr.Spec.Type)
+		assert.Equal(t, &v1beta1.ChartRepoSource{URL: "https://vcs.example.org/charts", Path: "charts/"}, cr.Spec.Source)
+	}
+}
@@ -1,6 +1,7 @@
 package plugin
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/alauda/helm-crds/pkg/apis/app/v1alpha1"
 	"github.com/alauda/helm-crds/pkg/apis/app/v1beta1"
@@ -8,13 +9,19 @@ import (
 	"github.com/alauda/helm-crds/pkg/client/clientset/versioned/scheme"
 	"github.com/pkg/errors"
 	"github.com/teris-io/shortid"
+	"io"
 	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
-	"k8s.io/klog"
+	"os/user"
+	"sort"
 	"strings"
 	"time"
 )
@@ -28,44 +35,106 @@ type CaptainContext struct {
 
 	// core client to create event
 	core kubernetes.Interface
+
+	// results go to streams.Out, progress and warnings go to streams.ErrOut
+	streams genericclioptions.IOStreams
+	// quiet suppress the progress messages
+	quiet bool
 }
 
 func NewCaptainContext(streams genericclioptions.IOStreams) *CaptainContext {
 	return &CaptainContext{
-		flags: genericclioptions.NewConfigFlags(true),
+		flags:   genericclioptions.NewConfigFlags(true),
+		streams: streams,
 	}
 }
 
-func (p *CaptainContext) Complete(namespace string) (err error) {
+func (p *CaptainContext) Complete(namespace string, quiet bool) (err error) {
 	p.namespace = namespace
+	p.quiet = quiet
 
 	configLoader := p.flags.ToRawKubeConfigLoader()
 
 	p.config, err = configLoader.ClientConfig()
 	if err != nil {
-		klog.Errorf("initial rest.Config obj config failed, err: %v", err)
-		return err
+		return errors.Wrap(err, "initial rest.Config obj config failed")
 	}
 
 	p.cli, err = clientset.NewForConfig(p.config)
 	if err != nil {
-		klog.Errorf("initial kubernetes.clientset obj cli failed, err: %v", err)
-		return err
+		return errors.Wrap(err, "initial kubernetes.clientset obj cli failed")
 	}
 
 	p.core, err = kubernetes.NewForConfig(p.config)
 	if err != nil {
-		klog.Errorf("init kubernetes core client failed, err: %v", err)
-		return err
+		return errors.Wrap(err, "init kubernetes core client failed")
 	}
 
 	return nil
 }
 
+// In is where to read user input from, eg: values file from stdin
+func (p *CaptainContext) In() io.Reader {
+	return p.streams.In
+}
+
+// Out is where to print the results
+func (p *CaptainContext) Out() io.Writer {
+	return p.streams.Out
+}
+
+// ErrOut is where to print progress and warnings
+func (p *CaptainContext) ErrOut() io.Writer {
+	return p.streams.ErrOut
+}
+
+// Infof print a progress message, unless in quiet mode
+func (p *CaptainContext) Infof(format string, args ...interface{}) {
+	if p.quiet {
+		return
+	}
+	fmt.Fprintf(p.streams.ErrOut, format+"\n", args...)
+}
+
+// Warningf print a warning message, it's not affected by quiet mode
+func (p *CaptainContext) Warningf(format string, args ...interface{}) {
+	fmt.Fprintf(p.streams.ErrOut, "Warning: "+format+"\n", args...)
+}
+
 func (p *CaptainContext) GetChartRepo(name, namespace string) (*v1beta1.ChartRepo, error) {
 	return p.cli.AppV1beta1().ChartRepos(namespace).Get(name, metav1.GetOptions{})
 }
 
+// ListChartRepos list the chartrepos in namespace, an empty namespace means all namespaces
+func (p *CaptainContext) ListChartRepos(namespace string) (*v1beta1.ChartRepoList, error) {
+	return p.cli.AppV1beta1().ChartRepos(namespace).List(metav1.ListOptions{})
+}
+
+// ListCharts list the charts synced from a chartrepo, captain labels them with the repo name
+func (p *CaptainContext) ListCharts(repo, namespace string) (*v1beta1.ChartList, error) {
+	opts := metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("repo=%s", repo),
+	}
+	return p.cli.AppV1beta1().Charts(namespace).List(opts)
+}
+
+// GetRepoSecret returns the secret referenced by a chartrepo, it's in the chartrepo's namespace if not set
+func (p *CaptainContext) GetRepoSecret(cr *v1beta1.ChartRepo) (*v1.Secret, error) {
+	ref := cr.Spec.Secret
+	if ref == nil {
+		return nil, fmt.Errorf("chartrepo %s has no secret", cr.Name)
+	}
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = cr.Namespace
+	}
+	return p.core.CoreV1().Secrets(namespace).Get(ref.Name, metav1.GetOptions{})
+}
+
+func (p *CaptainContext) DeleteChartRepo(name, namespace string) error {
+	return p.cli.AppV1beta1().ChartRepos(namespace).Delete(name, &metav1.DeleteOptions{})
+}
+
 func (p *CaptainContext) UpdateChartRepo(repo *v1beta1.ChartRepo) (*v1beta1.ChartRepo, error) {
 	return p.cli.AppV1beta1().ChartRepos(p.namespace).Update(repo)
 }
@@ -74,6 +143,31 @@ func (p *CaptainContext) PatchChartRepo(name string, data []byte) (result *v1bet
 	return p.cli.AppV1beta1().ChartRepos(p.namespace).Patch(name, types.MergePatchType, data)
 }
 
+// PatchChartRepoStatus merge patch the status of a chartrepo through the status subresource, status changes
+// to the chartrepo itself are dropped when the subresource is enabled. Old CRDs without the subresource
+// get the patch on the chartrepo.
+func (p *CaptainContext) PatchChartRepoStatus(name string, data []byte) (*v1beta1.ChartRepo, error) {
+	client := p.cli.AppV1beta1().ChartRepos(p.namespace)
+	result, err := client.Patch(name, types.MergePatchType, data, "status")
+	if apierrors.IsNotFound(err) {
+		return client.Patch(name, types.MergePatchType, data)
+	}
+	return result, err
+}
+
+// ReleaseName returns the name and namespace of the release generated from hr
+func ReleaseName(hr *v1alpha1.HelmRequest) (name, namespace string) {
+	name = hr.Spec.ReleaseName
+	if name == "" {
+		name = hr.Name
+	}
+	namespace = hr.Spec.Namespace
+	if namespace == "" {
+		namespace = hr.Namespace
+	}
+	return name, namespace
+}
+
 // there should be only one deployed release for each helmrequest
 func (p *CaptainContext) GetDeployedRelease(name, namespace string) (*v1alpha1.Release, error) {
 	opts := metav1.ListOptions{
@@ -91,10 +185,64 @@ func (p *CaptainContext) GetDeployedRelease(name, namespace string) (*v1alpha1.R
 	return &result.Items[0], nil
 }
 
+// ListReleases returns all the releases kept for the release name, sorted by revision
+func (p *CaptainContext) ListReleases(name, namespace string) ([]v1alpha1.Release, error) {
+	opts := metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("name=%s", name),
+	}
+	result, err := p.cli.AppV1alpha1().Releases(namespace).List(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	items := result.Items
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Spec.Version < items[j].Spec.Version
+	})
+	return items, nil
+}
+
+// GetRelease returns the release of the given revision, the release is kept until it's out of the max history
+func (p *CaptainContext) GetRelease(name, namespace string, revision int) (*v1alpha1.Release, error) {
+	return p.cli.AppV1alpha1().Releases(namespace).Get(makeKey(name, revision), metav1.GetOptions{})
+}
+
 func (p *CaptainContext) GetHelmRequest(name string) (*v1alpha1.HelmRequest, error) {
 	return p.cli.AppV1alpha1().HelmRequests(p.namespace).Get(name, metav1.GetOptions{})
 }
 
+// ListHelmRequests list helmrequests in namespace by label selector, an empty namespace means all namespaces
+func (p *CaptainContext) ListHelmRequests(namespace, selector string) (*v1alpha1.HelmRequestList, error) {
+	return p.cli.AppV1alpha1().HelmRequests(namespace).List(metav1.ListOptions{LabelSelector: selector})
+}
+
+func (p *CaptainContext) PatchHelmRequest(name string, data []byte) (*v1alpha1.HelmRequest, error) {
+	return p.cli.AppV1alpha1().HelmRequests(p.namespace).Patch(name, types.MergePatchType, data)
+}
+
+// ResyncHelmRequest reset the phase of a helmrequest to Pending through the status subresource, so captain
+// will sync it again
+func (p *CaptainContext) ResyncHelmRequest(name string) (*v1alpha1.HelmRequest, error) {
+	data, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{"phase": v1alpha1.HelmRequestPending},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p.PatchHelmRequestStatus(name, data)
+}
+
+// PatchHelmRequestStatus merge patch the status of a helmrequest through the status subresource, like
+// PatchChartRepoStatus
+func (p *CaptainContext) PatchHelmRequestStatus(name string, data []byte) (*v1alpha1.HelmRequest, error) {
+	client := p.cli.AppV1alpha1().HelmRequests(p.namespace)
+	result, err := client.Patch(name, types.MergePatchType, data, "status")
+	if apierrors.IsNotFound(err) {
+		return client.Patch(name, types.MergePatchType, data)
+	}
+	return result, err
+}
+
 func (p *CaptainContext) CreateHelmRequest(new *v1alpha1.HelmRequest) (*v1alpha1.HelmRequest, error) {
 	return p.cli.AppV1alpha1().HelmRequests(new.GetNamespace()).Create(new)
 }
@@ -103,12 +251,18 @@ func (p *CaptainContext) UpdateHelmRequest(new *v1alpha1.HelmRequest) (*v1alpha1
 	return p.cli.AppV1alpha1().HelmRequests(p.namespace).Update(new)
 }
 
+// DeleteHelmRequest delete the helmrequest in the working namespace, captain uninstalls it's release
+// before the object is gone
+func (p *CaptainContext) DeleteHelmRequest(name string) error {
+	return p.cli.AppV1alpha1().HelmRequests(p.namespace).Delete(name, &metav1.DeleteOptions{})
+}
+
 func (p *CaptainContext) UpdateHelmRequestStatus(new *v1alpha1.HelmRequest) (*v1alpha1.HelmRequest, error) {
 	return p.cli.AppV1alpha1().HelmRequests(p.namespace).UpdateStatus(new)
 }
 
-func (p *CaptainContext) CreateChartRepo(new *v1alpha1.ChartRepo) (*v1alpha1.ChartRepo, error) {
-	return p.cli.AppV1alpha1().ChartRepos(new.GetNamespace()).Create(new)
+func (p *CaptainContext) CreateChartRepo(new *v1beta1.ChartRepo) (*v1beta1.ChartRepo, error) {
+	return p.cli.AppV1beta1().ChartRepos(new.GetNamespace()).Create(new)
 }
 
 func (p *CaptainContext) GetNamespace() string {
@@ -119,18 +273,83 @@ func (p *CaptainContext) GetRestConfig() *rest.Config {
 	return p.config
 }
 
+// GetUser returns the kubeconfig user of the current context, fallback to the os user
+func (p *CaptainContext) GetUser() string {
+	raw, err := p.flags.ToRawKubeConfigLoader().RawConfig()
+	if err == nil {
+		if ctx, ok := raw.Contexts[raw.CurrentContext]; ok && ctx.AuthInfo != "" {
+			return ctx.AuthInfo
+		}
+	}
+
+	usr, err := user.Current()
+	if err != nil {
+		return ""
+	}
+	return usr.Username
+}
+
 func (p *CaptainContext) GetConfigMap(name string) (*v1.ConfigMap, error) {
 	return p.core.CoreV1().ConfigMaps(p.namespace).Get(name, metav1.GetOptions{})
 }
 
+func (p *CaptainContext) GetSecret(name string) (*v1.Secret, error) {
+	return p.core.CoreV1().Secrets(p.namespace).Get(name, metav1.GetOptions{})
+}
+
+func (p *CaptainContext) DeleteSecret(name, namespace string) error {
+	return p.core.CoreV1().Secrets(namespace).Delete(name, &metav1.DeleteOptions{})
+}
+
+// GetEvents returns events of the helmrequest or chartrepo in chronological order
+func (p *CaptainContext) GetEvents(obj runtime.Object) ([]v1.Event, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+	events, err := p.core.CoreV1().Events(accessor.GetNamespace()).Search(scheme.Scheme, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	items := events.Items
+	sort.SliceStable(items, func(i, j int) bool {
+		return eventTime(&items[i]).Before(eventTime(&items[j]))
+	})
+	return items, nil
+}
+
+// GetEventsByObject list the events of the objects of kind in namespace at once and group them by the uid
+// of their object, in chronological order. An empty namespace means all namespaces.
+func (p *CaptainContext) GetEventsByObject(namespace, kind string) (map[types.UID][]v1.Event, error) {
+	opts := metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("involvedObject.kind", kind).String(),
+	}
+	events, err := p.core.CoreV1().Events(namespace).List(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	items := events.Items
+	sort.SliceStable(items, func(i, j int) bool {
+		return eventTime(&items[i]).Before(eventTime(&items[j]))
+	})
+	result := map[types.UID][]v1.Event{}
+	for _, event := range items {
+		uid := event.InvolvedObject.UID
+		result[uid] = append(result[uid], event)
+	}
+	return result, nil
+}
+
 func (p *CaptainContext) GetEventsMessage(hr *v1alpha1.HelmRequest) (string, error) {
-	events, err := p.core.CoreV1().Events(hr.Namespace).Search(scheme.Scheme, hr)
+	events, err := p.GetEvents(hr)
 	if err != nil {
 		return "", err
 	}
 
 	msg := ""
-	for _, event := range events.Items {
+	for _, event := range events {
 		msg += event.Message + ","
 	}
 
@@ -138,6 +357,42 @@ func (p *CaptainContext) GetEventsMessage(hr *v1alpha1.HelmRequest) (string, err
 
 }
 
+// FailureReason returns the message of the latest Warning event, captain records why a sync failed there
+func FailureReason(events []v1.Event) string {
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Type == v1.EventTypeWarning {
+			return strings.TrimSpace(events[i].Message)
+		}
+	}
+	return ""
+}
+
+// SyncedReason is the reason of the Normal event captain records after a sync succeeded
+const SyncedReason = "Synced"
+
+// LastSyncTime returns the time of the latest Normal Synced event, it's zero if there is none
+func LastSyncTime(events []v1.Event) time.Time {
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Type == v1.EventTypeNormal && events[i].Reason == SyncedReason {
+			return eventTime(&events[i])
+		}
+	}
+	return time.Time{}
+}
+
+// eventTime returns the last time the event occurred
+func eventTime(event *v1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	case !event.FirstTimestamp.IsZero():
+		return event.FirstTimestamp.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
 // CreateEvent a event for upgrade/rollback...
 func (p *CaptainContext) CreateEvent(et string, reason, message string, hr *v1alpha1.HelmRequest) {
 
@@ -167,7 +422,7 @@ func (p *CaptainContext) CreateEvent(et string, reason, message string, hr *v1al
 	}
 	_, err := p.core.CoreV1().Events(hr.Namespace).Create(&event)
 	if err != nil {
-		klog.Errorf("create event for helmrequest %s error: %s", hr.Name, err.Error())
+		p.Warningf("create event for helmrequest %s error: %s", hr.Name, err.Error())
 	}
 	return
 }
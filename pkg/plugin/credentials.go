@@ -0,0 +1,174 @@
+package plugin
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"strconv"
+
+	"github.com/alauda/helm-crds/pkg/apis/app/v1beta1"
+	"github.com/pkg/errors"
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// the keys of a chartrepo secret
+const (
+	RepoUsernameKey = "username"
+	RepoPasswordKey = "password"
+	// RepoCAKey is the CA bundle to verify the repo's certificate
+	RepoCAKey = "ca.crt"
+	// RepoCertKey and RepoKeyKey are the client certificate and key
+	RepoCertKey = "tls.crt"
+	RepoKeyKey  = "tls.key"
+	// RepoInsecureKey is "true" to skip verifying the repo's certificate
+	RepoInsecureKey = "insecureSkipTLSVerify"
+)
+
+// RepoSecretLabel marks the chartrepo secrets created by this plugin, only they are deleted along with
+// their chartrepos
+const RepoSecretLabel = "kubectl-captain.chartrepo-secret"
+
+// RepoCredentials are what the secret of a chartrepo holds. When updating a secret, the empty fields
+// keep the stored ones.
+type RepoCredentials struct {
+	Username string
+	Password string
+
+	CAData   []byte
+	CertData []byte
+	KeyData  []byte
+	// InsecureSkipTLSVerify is only changed if it's not nil
+	InsecureSkipTLSVerify *bool
+}
+
+// IsEmpty tells whether nothing is set
+func (c *RepoCredentials) IsEmpty() bool {
+	return c.Username == "" && c.Password == "" && len(c.CAData) == 0 && len(c.CertData) == 0 &&
+		len(c.KeyData) == 0 && c.InsecureSkipTLSVerify == nil
+}
+
+// apply write the non-empty fields to data
+func (c *RepoCredentials) apply(data map[string][]byte) {
+	set := func(key string, value []byte) {
+		if len(value) > 0 {
+			data[key] = value
+		}
+	}
+	set(RepoUsernameKey, []byte(c.Username))
+	set(RepoPasswordKey, []byte(c.Password))
+	set(RepoCAKey, c.CAData)
+	set(RepoCertKey, c.CertData)
+	set(RepoKeyKey, c.KeyData)
+	if c.InsecureSkipTLSVerify != nil {
+		if *c.InsecureSkipTLSVerify {
+			data[RepoInsecureKey] = []byte("true")
+		} else {
+			delete(data, RepoInsecureKey)
+		}
+	}
+}
+
+// CreateRepoSecret create the secret holding the credentials of a chartrepo, it fails if the secret
+// already exists
+func (p *CaptainContext) CreateRepoSecret(name, namespace string, creds RepoCredentials) (*v1.Secret, error) {
+	if (creds.Username == "") != (creds.Password == "") {
+		return nil, fmt.Errorf("both username and password are needed to create secret %s", name)
+	}
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{RepoSecretLabel: "true"},
+		},
+		Data: map[string][]byte{},
+	}
+	creds.apply(secret.Data)
+	if _, err := repoTLSConfig(secret); err != nil {
+		return nil, err
+	}
+	return p.core.CoreV1().Secrets(namespace).Create(secret)
+}
+
+// CreateChartRepoWithSecret create the secret cr.Spec.Secret refers to from creds, then the chartrepo.
+// An existing secret is never touched, and the new one is deleted again if the chartrepo can't be created.
+func (p *CaptainContext) CreateChartRepoWithSecret(cr *v1beta1.ChartRepo, creds RepoCredentials) (*v1beta1.ChartRepo, error) {
+	ref := cr.Spec.Secret
+	if ref == nil || creds.IsEmpty() {
+		return p.CreateChartRepo(cr)
+	}
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = cr.Namespace
+	}
+
+	if _, err := p.CreateRepoSecret(ref.Name, namespace, creds); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			return nil, fmt.Errorf("secret %s/%s already exists, refuse to overwrite it for chartrepo %s, "+
+				"use update-repo to change the credentials of an existing chartrepo", namespace, ref.Name, cr.Name)
+		}
+		return nil, errors.Wrap(err, "create chartrepo secret error")
+	}
+
+	created, err := p.CreateChartRepo(cr)
+	if err != nil {
+		if derr := p.DeleteSecret(ref.Name, namespace); derr != nil && !apierrors.IsNotFound(derr) {
+			p.Warningf("delete secret %s/%s error: %s", namespace, ref.Name, derr.Error())
+		}
+		return nil, errors.Wrap(err, "create chartrepo error")
+	}
+	return created, nil
+}
+
+// ApplyRepoSecret create the secret holding the credentials of a chartrepo, or update it if it already exists
+func (p *CaptainContext) ApplyRepoSecret(name, namespace string, creds RepoCredentials) (*v1.Secret, error) {
+	client := p.core.CoreV1().Secrets(namespace)
+	secret, err := client.Get(name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return p.CreateRepoSecret(name, namespace, creds)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	creds.apply(secret.Data)
+	if _, err := repoTLSConfig(secret); err != nil {
+		return nil, err
+	}
+	return client.Update(secret)
+}
+
+// IsPluginRepoSecret tells whether the secret is created by ApplyRepoSecret
+func IsPluginRepoSecret(secret *v1.Secret) bool {
+	return secret.Labels[RepoSecretLabel] == "true"
+}
+
+// repoTLSConfig build the tls config from a chartrepo secret, it's nil if the secret has no tls settings
+func repoTLSConfig(secret *v1.Secret) (*tls.Config, error) {
+	ca, cert, key := secret.Data[RepoCAKey], secret.Data[RepoCertKey], secret.Data[RepoKeyKey]
+	insecure, _ := strconv.ParseBool(string(secret.Data[RepoInsecureKey]))
+	if len(ca) == 0 && len(cert) == 0 && len(key) == 0 && !insecure {
+		return nil, nil
+	}
+
+	config := &tls.Config{InsecureSkipVerify: insecure}
+	if len(ca) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no valid certificate found in %s", RepoCAKey)
+		}
+		config.RootCAs = pool
+	}
+	if len(cert) > 0 || len(key) > 0 {
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %s", err.Error())
+		}
+		config.Certificates = []tls.Certificate{pair}
+	}
+	return config, nil
+}
@@ -0,0 +1,128 @@
+package plugin
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alauda/helm-crds/pkg/apis/app/v1beta1"
+	crdfake "github.com/alauda/helm-crds/pkg/client/clientset/versioned/fake"
+	"github.com/gsamokovarov/assert"
+	"helm.sh/helm/pkg/chartutil"
+	"helm.sh/helm/pkg/repo"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestApplyRepoSecret(t *testing.T) {
+	p := &CaptainContext{core: fake.NewSimpleClientset()}
+
+	_, err := p.ApplyRepoSecret("stable", "alauda-system", RepoCredentials{Password: "secret"})
+	assert.NotNil(t, err)
+
+	secret, err := p.ApplyRepoSecret("stable", "alauda-system", RepoCredentials{Username: "admin", Password: "secret"})
+	assert.Nil(t, err)
+	assert.Equal(t, "admin", string(secret.Data[RepoUsernameKey]))
+	assert.True(t, IsPluginRepoSecret(secret))
+
+	// rotate the password only
+	secret, err = p.ApplyRepoSecret("stable", "alauda-system", RepoCredentials{Password: "changed"})
+	assert.Nil(t, err)
+	assert.Equal(t, "admin", string(secret.Data[RepoUsernameKey]))
+	assert.Equal(t, "changed", string(secret.Data[RepoPasswordKey]))
+
+	insecure := true
+	secret, err = p.ApplyRepoSecret("stable", "alauda-system", RepoCredentials{InsecureSkipTLSVerify: &insecure})
+	assert.Nil(t, err)
+	assert.Equal(t, "true", string(secret.Data[RepoInsecureKey]))
+	insecure = false
+	secret, err = p.ApplyRepoSecret("stable", "alauda-system", RepoCredentials{InsecureSkipTLSVerify: &insecure})
+	assert.Nil(t, err)
+	_, ok := secret.Data[RepoInsecureKey]
+	assert.False(t, ok)
+
+	_, err = p.ApplyRepoSecret("stable", "alauda-system", RepoCredentials{CAData: []byte("not a certificate")})
+	assert.NotNil(t, err)
+}
+
+func TestCreateChartRepoWithSecret(t *testing.T) {
+	foreign := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "taken", Namespace: "alauda-system"},
+		Data:       map[string][]byte{"token": []byte("keep")},
+	}
+	p := &CaptainContext{
+		cli:  crdfake.NewSimpleClientset(&v1beta1.ChartRepo{ObjectMeta: metav1.ObjectMeta{Name: "exists", Namespace: "alauda-system"}}),
+		core: fake.NewSimpleClientset(foreign),
+	}
+	creds := RepoCredentials{Username: "admin", Password: "secret"}
+	newRepo := func(name string) *v1beta1.ChartRepo {
+		cr := NewChartRepo(name, "alauda-system", v1beta1.ChartRepoChart, "https://charts.example.org", "")
+		cr.Spec.Secret = &v1.SecretReference{Name: name}
+		return cr
+	}
+
+	_, err := p.CreateChartRepoWithSecret(newRepo("stable"), creds)
+	assert.Nil(t, err)
+	secret, err := p.core.CoreV1().Secrets("alauda-system").Get("stable", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.True(t, IsPluginRepoSecret(secret))
+
+	// an existing secret is never updated
+	_, err = p.CreateChartRepoWithSecret(newRepo("taken"), creds)
+	assert.NotNil(t, err)
+	secret, err = p.core.CoreV1().Secrets("alauda-system").Get("taken", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "keep", string(secret.Data["token"]))
+	_, ok := secret.Data[RepoUsernameKey]
+	assert.False(t, ok)
+	_, err = p.GetChartRepo("taken", "alauda-system")
+	assert.NotNil(t, err)
+
+	// the new secret is removed when the chartrepo can't be created
+	_, err = p.CreateChartRepoWithSecret(newRepo("exists"), creds)
+	assert.NotNil(t, err)
+	_, err = p.core.CoreV1().Secrets("alauda-system").Get("exists", metav1.GetOptions{})
+	assert.NotNil(t, err)
+}
+
+func TestLoadChartTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "charts")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = chartutil.Save(newTestChart(), dir)
+	assert.Nil(t, err)
+	index, err := repo.IndexDirectory(dir, "")
+	assert.Nil(t, err)
+	assert.Nil(t, index.WriteFile(filepath.Join(dir, "index.yaml"), 0644))
+
+	server := httptest.NewTLSServer(http.FileServer(http.Dir(dir)))
+	defer server.Close()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	cr := &v1beta1.ChartRepo{
+		ObjectMeta: metav1.ObjectMeta{Name: "internal", Namespace: "alauda-system"},
+		Spec: v1beta1.ChartRepoSpec{
+			URL:    server.URL,
+			Secret: &v1.SecretReference{Name: "internal"},
+		},
+	}
+
+	// the server's certificate is not trusted without the CA
+	p := &CaptainContext{cli: crdfake.NewSimpleClientset(cr), core: fake.NewSimpleClientset()}
+	_, err = p.ApplyRepoSecret("internal", "alauda-system", RepoCredentials{Username: "admin", Password: "secret"})
+	assert.Nil(t, err)
+	_, err = p.LoadChart("internal/demo", "0.1.0", "alauda-system")
+	assert.NotNil(t, err)
+
+	_, err = p.ApplyRepoSecret("internal", "alauda-system", RepoCredentials{CAData: ca})
+	assert.Nil(t, err)
+	ch, err := p.LoadChart("internal/demo", "0.1.0", "alauda-system")
+	assert.Nil(t, err)
+	assert.Equal(t, "demo", ch.Name())
+}
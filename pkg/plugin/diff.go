@@ -0,0 +1,86 @@
+package plugin
+
+import (
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// UnifiedDiff returns the unified diff from a to b, it's empty if they are the same
+func UnifiedDiff(fromFile, toFile, a, b string) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(a),
+		B:        difflib.SplitLines(b),
+		FromFile: fromFile,
+		ToFile:   toFile,
+		Context:  3,
+	})
+}
+
+// DiffYAML marshal a and b to yaml and diff them, keys are sorted so only real changes show up
+func DiffYAML(fromFile, toFile string, a, b interface{}) (string, error) {
+	x, err := yaml.Marshal(a)
+	if err != nil {
+		return "", err
+	}
+	y, err := yaml.Marshal(b)
+	if err != nil {
+		return "", err
+	}
+	return UnifiedDiff(fromFile, toFile, string(x), string(y))
+}
+
+// DiffManifests diff the manifests resource by resource, so the diff does not depend on the order of the
+// resources. Each diff is labeled by <from|to>/<kind>/[<namespace>/]<name>, added and removed resources are diffed
+// with nothing.
+func DiffManifests(from, to, a, b string) (string, error) {
+	x, err := ParseManifest(a)
+	if err != nil {
+		return "", err
+	}
+	y, err := ParseManifest(b)
+	if err != nil {
+		return "", err
+	}
+
+	key := func(doc ManifestDoc) string {
+		if doc.Namespace != "" {
+			return strings.ToLower(doc.Kind) + "/" + doc.Namespace + "/" + doc.Name
+		}
+		return strings.ToLower(doc.Kind) + "/" + doc.Name
+	}
+
+	// resources of a keep their order, the added ones follow in the order of b
+	var keys []string
+	contents := map[string][2]string{}
+	for _, doc := range x {
+		k := key(doc)
+		if _, ok := contents[k]; !ok {
+			keys = append(keys, k)
+		}
+		c := contents[k]
+		c[0] += doc.Content
+		contents[k] = c
+	}
+	for _, doc := range y {
+		k := key(doc)
+		if _, ok := contents[k]; !ok {
+			keys = append(keys, k)
+		}
+		c := contents[k]
+		c[1] += doc.Content
+		contents[k] = c
+	}
+
+	var result strings.Builder
+	for _, k := range keys {
+		c := contents[k]
+		diff, err := UnifiedDiff(from+"/"+k, to+"/"+k, c[0], c[1])
+		if err != nil {
+			return "", err
+		}
+		result.WriteString(diff)
+	}
+	return result.String(), nil
+}
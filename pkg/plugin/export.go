@@ -0,0 +1,84 @@
+package plugin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"helm.sh/helm/pkg/chart"
+	"helm.sh/helm/pkg/chartutil"
+	rspb "helm.sh/helm/pkg/release"
+)
+
+// ExportRelease write the chart of a release to dir, as a directory named by the chart or as
+// <chart>-<version>.tgz, and the user values of the release to <release>-<revision>.values.yaml beside it.
+// It returns the paths of the chart and the values file.
+func ExportRelease(rel *rspb.Release, dir string, tgz bool) (chartPath, valuesPath string, err error) {
+	if rel.Chart == nil || rel.Chart.Metadata == nil {
+		return "", "", fmt.Errorf("release %s has no chart data", rel.Name)
+	}
+
+	if err := checkChartPaths(rel.Chart); err != nil {
+		return "", "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", "", err
+	}
+
+	if tgz {
+		if chartPath, err = chartutil.Save(rel.Chart, dir); err != nil {
+			return "", "", err
+		}
+	} else {
+		chartPath = filepath.Join(dir, rel.Chart.Name())
+		// SaveDir only creates the top level templates directory
+		for _, f := range rel.Chart.Templates {
+			if err := os.MkdirAll(filepath.Dir(filepath.Join(chartPath, f.Name)), 0755); err != nil {
+				return "", "", err
+			}
+		}
+		if err := chartutil.SaveDir(rel.Chart, dir); err != nil {
+			return "", "", err
+		}
+	}
+
+	config := rel.Config
+	if config == nil {
+		config = map[string]interface{}{}
+	}
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return "", "", err
+	}
+	valuesPath = filepath.Join(dir, fmt.Sprintf("%s-%d.values.yaml", rel.Name, rel.Version))
+	if err := ioutil.WriteFile(valuesPath, data, 0644); err != nil {
+		return "", "", err
+	}
+	return chartPath, valuesPath, nil
+}
+
+// checkChartPaths make sure the chart, its dependencies and every file of them are written inside
+// the export directory. The chart data comes from the cluster, so the names are not trusted.
+func checkChartPaths(ch *chart.Chart) error {
+	name := ch.Name()
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid chart name %q", name)
+	}
+	for _, files := range [][]*chart.File{ch.Templates, ch.Files} {
+		for _, f := range files {
+			p := filepath.Clean(filepath.FromSlash(f.Name))
+			if filepath.IsAbs(p) || p == "." || p == ".." || strings.HasPrefix(p, ".."+string(filepath.Separator)) {
+				return fmt.Errorf("file %q of chart %s is outside of the chart directory", f.Name, name)
+			}
+		}
+	}
+	for _, dep := range ch.Dependencies() {
+		if err := checkChartPaths(dep); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package plugin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gsamokovarov/assert"
+	"helm.sh/helm/pkg/chart"
+	"helm.sh/helm/pkg/chart/loader"
+	rspb "helm.sh/helm/pkg/release"
+)
+
+func TestExportRelease(t *testing.T) {
+	dir, err := ioutil.TempDir("", "export")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	rel := &rspb.Release{
+		Name:    "foo",
+		Version: 3,
+		Chart:   newTestChart(),
+		Config:  map[string]interface{}{"replicas": 2},
+	}
+
+	chartPath, valuesPath, err := ExportRelease(rel, filepath.Join(dir, "dir"), false)
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(dir, "dir", "demo"), chartPath)
+	assert.Equal(t, filepath.Join(dir, "dir", "foo-3.values.yaml"), valuesPath)
+	ch, err := loader.Load(chartPath)
+	assert.Nil(t, err)
+	assert.Equal(t, "0.1.0", ch.Metadata.Version)
+	assert.Equal(t, len(rel.Chart.Templates), len(ch.Templates))
+	data, err := ioutil.ReadFile(valuesPath)
+	assert.Nil(t, err)
+	assert.Equal(t, "replicas: 2\n", string(data))
+
+	chartPath, _, err = ExportRelease(rel, filepath.Join(dir, "tgz"), true)
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(dir, "tgz", "demo-0.1.0.tgz"), chartPath)
+	ch, err = loader.Load(chartPath)
+	assert.Nil(t, err)
+	assert.Equal(t, "demo", ch.Name())
+
+	_, _, err = ExportRelease(&rspb.Release{Name: "foo"}, dir, false)
+	assert.NotNil(t, err)
+}
+
+func TestExportReleasePaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "export")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	nested := newTestChart()
+	nested.Templates = append(nested.Templates, &chart.File{Name: "templates/sub/cm.yaml", Data: []byte("kind: ConfigMap\n")})
+	chartPath, _, err := ExportRelease(&rspb.Release{Name: "foo", Version: 1, Chart: nested}, dir, false)
+	assert.Nil(t, err)
+	data, err := ioutil.ReadFile(filepath.Join(chartPath, "templates", "sub", "cm.yaml"))
+	assert.Nil(t, err)
+	assert.Equal(t, "kind: ConfigMap\n", string(data))
+
+	for _, name := range []string{"../evil.yaml", "templates/../../evil.yaml", "/tmp/evil.yaml"} {
+		evil := newTestChart()
+		evil.Templates = append(evil.Templates, &chart.File{Name: name, Data: []byte("evil")})
+		out := filepath.Join(dir, "evil")
+		_, _, err := ExportRelease(&rspb.Release{Name: "foo", Version: 1, Chart: evil}, out, false)
+		assert.NotNil(t, err)
+		_, _, err = ExportRelease(&rspb.Release{Name: "foo", Version: 1, Chart: evil}, out, true)
+		assert.NotNil(t, err)
+		_, err = os.Stat(filepath.Join(out, "evil.yaml"))
+		assert.True(t, os.IsNotExist(err))
+	}
+
+	evil := newTestChart()
+	evil.Metadata.Name = ".."
+	_, _, err = ExportRelease(&rspb.Release{Name: "foo", Version: 1, Chart: evil}, dir, false)
+	assert.NotNil(t, err)
+}
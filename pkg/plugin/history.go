@@ -0,0 +1,140 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/alauda/helm-crds/pkg/apis/app/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// HistoryAnnotation stores the revision history of a helmrequest's spec
+	HistoryAnnotation = "kubectl-captain.history"
+
+	// LastSpecAnnotation is where older versions of this plugin stored the previous spec
+	LastSpecAnnotation = "last-spec"
+
+	// DefaultHistoryMax is the max number of revisions kept for a helmrequest, annotations have a size limit
+	DefaultHistoryMax = 10
+
+	// HistoryMaxBytes is the max size of the history annotation. All the annotations of an object share a
+	// 256KB limit, so leave the other half to the rest.
+	HistoryMaxBytes = 128 * 1024
+)
+
+// Revision is a snapshot of a HelmRequestSpec and who made it
+type Revision struct {
+	Revision  int                      `json:"revision"`
+	Timestamp metav1.Time              `json:"timestamp"`
+	User      string                   `json:"user,omitempty"`
+	Command   string                   `json:"command,omitempty"`
+	Spec      v1alpha1.HelmRequestSpec `json:"spec"`
+}
+
+// GetHistory decode the revision history of a helmrequest, oldest first
+func GetHistory(hr *v1alpha1.HelmRequest) ([]Revision, error) {
+	var history []Revision
+	data := hr.Annotations[HistoryAnnotation]
+	if data == "" {
+		return history, nil
+	}
+	if err := json.Unmarshal([]byte(data), &history); err != nil {
+		return nil, fmt.Errorf("decode history of helmrequest %s error: %s", hr.Name, err.Error())
+	}
+	return history, nil
+}
+
+// FindRevision find the given revision in history
+func FindRevision(history []Revision, revision int) (*Revision, error) {
+	for i := range history {
+		if history[i].Revision == revision {
+			return &history[i], nil
+		}
+	}
+	return nil, fmt.Errorf("revision %d not found in history", revision)
+}
+
+// PreviousRevision returns the revision to rollback to by default, it's the one before the latest as the
+// latest is the current spec. If the spec has been changed outside this plugin since, eg: by kubectl edit,
+// the latest revision is returned and changed is true. rev is nil if there is no such revision.
+func PreviousRevision(hr *v1alpha1.HelmRequest, history []Revision) (rev *Revision, changed bool) {
+	if len(history) == 0 {
+		return nil, false
+	}
+	latest := &history[len(history)-1]
+	if !equality.Semantic.DeepEqual(latest.Spec, hr.Spec) {
+		return latest, true
+	}
+	if len(history) == 1 {
+		return nil, false
+	}
+	return &history[len(history)-2], false
+}
+
+// RecordRevision append the current spec of hr to it's history, only the latest max revisions that fit in
+// HistoryMaxBytes are kept.
+// If hr has no history yet, previous (and the legacy last-spec annotation) will be recorded first,
+// so the spec before this change can be restored.
+func RecordRevision(hr *v1alpha1.HelmRequest, previous *v1alpha1.HelmRequestSpec, user, command string, max int) error {
+	history, err := GetHistory(hr)
+	if err != nil {
+		return err
+	}
+
+	if hr.Annotations == nil {
+		hr.Annotations = make(map[string]string)
+	}
+
+	add := func(spec v1alpha1.HelmRequestSpec, timestamp time.Time, user, command string) {
+		revision := 1
+		if len(history) > 0 {
+			revision = history[len(history)-1].Revision + 1
+		}
+		history = append(history, Revision{
+			Revision:  revision,
+			Timestamp: metav1.NewTime(timestamp),
+			User:      user,
+			Command:   command,
+			Spec:      spec,
+		})
+	}
+
+	if len(history) == 0 {
+		if data := hr.Annotations[LastSpecAnnotation]; data != "" {
+			var legacy v1alpha1.HelmRequestSpec
+			if err := json.Unmarshal([]byte(data), &legacy); err == nil {
+				add(legacy, hr.CreationTimestamp.Time, "", "")
+			}
+		}
+		if previous != nil {
+			add(*previous, hr.CreationTimestamp.Time, "", "")
+		}
+	}
+	delete(hr.Annotations, LastSpecAnnotation)
+
+	add(*hr.Spec.DeepCopy(), time.Now(), user, command)
+
+	if max > 0 && len(history) > max {
+		history = history[len(history)-max:]
+	}
+
+	// drop the oldest revisions until the annotation fits, large values would make the object rejected
+	for len(history) > 0 {
+		data, err := json.Marshal(history)
+		if err != nil {
+			return err
+		}
+		if len(data) <= HistoryMaxBytes {
+			hr.Annotations[HistoryAnnotation] = string(data)
+			return nil
+		}
+		history = history[1:]
+	}
+
+	// even the current spec is too large to be recorded
+	delete(hr.Annotations, HistoryAnnotation)
+	return nil
+}
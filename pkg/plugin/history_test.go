@@ -0,0 +1,83 @@
+package plugin
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alauda/helm-crds/pkg/apis/app/v1alpha1"
+	"github.com/gsamokovarov/assert"
+)
+
+func TestRecordRevision(t *testing.T) {
+	var hr v1alpha1.HelmRequest
+	hr.Name = "foo"
+	hr.Spec.Version = "1.0.0"
+	hr.Annotations = map[string]string{LastSpecAnnotation: `{"version":"0.9.0"}`}
+
+	previous := hr.Spec.DeepCopy()
+	hr.Spec.Version = "1.1.0"
+	assert.Nil(t, RecordRevision(&hr, previous, "tom", "upgrade", 3))
+
+	history, err := GetHistory(&hr)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(history))
+	assert.Equal(t, "0.9.0", history[0].Spec.Version)
+	assert.Equal(t, "1.0.0", history[1].Spec.Version)
+	assert.Equal(t, "1.1.0", history[2].Spec.Version)
+	assert.Equal(t, "tom", history[2].User)
+	assert.Equal(t, "", hr.Annotations[LastSpecAnnotation])
+
+	hr.Spec.Version = "1.2.0"
+	assert.Nil(t, RecordRevision(&hr, nil, "tom", "upgrade", 3))
+
+	history, err = GetHistory(&hr)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(history))
+	assert.Equal(t, 2, history[0].Revision)
+	assert.Equal(t, 4, history[2].Revision)
+
+	rev, err := FindRevision(history, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, "1.1.0", rev.Spec.Version)
+
+	_, err = FindRevision(history, 1)
+	assert.NotNil(t, err)
+
+	rev, changed := PreviousRevision(&hr, history)
+	assert.False(t, changed)
+	assert.Equal(t, 3, rev.Revision)
+
+	// changed by kubectl edit
+	hr.Spec.Version = "1.3.0"
+	rev, changed = PreviousRevision(&hr, history)
+	assert.True(t, changed)
+	assert.Equal(t, 4, rev.Revision)
+
+	rev, _ = PreviousRevision(&hr, nil)
+	assert.Nil(t, rev)
+}
+
+func TestRecordRevisionMaxBytes(t *testing.T) {
+	var hr v1alpha1.HelmRequest
+	hr.Name = "foo"
+
+	// every spec takes about 40KB, so only 3 of them fit in the annotation
+	large := strings.Repeat("x", 40*1024)
+	for i := 0; i < 5; i++ {
+		hr.Spec.Values = map[string]interface{}{"data": large, "index": i}
+		assert.Nil(t, RecordRevision(&hr, nil, "tom", "upgrade", DefaultHistoryMax))
+		assert.True(t, len(hr.Annotations[HistoryAnnotation]) <= HistoryMaxBytes)
+	}
+
+	history, err := GetHistory(&hr)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(history))
+	assert.Equal(t, 3, history[0].Revision)
+	assert.Equal(t, 5, history[2].Revision)
+
+	// a spec too large to be recorded drops the history instead of making the object rejected
+	hr.Spec.Values = map[string]interface{}{"data": strings.Repeat("x", HistoryMaxBytes)}
+	assert.Nil(t, RecordRevision(&hr, nil, "tom", "upgrade", DefaultHistoryMax))
+	_, ok := hr.Annotations[HistoryAnnotation]
+	assert.False(t, ok)
+}
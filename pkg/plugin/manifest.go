@@ -0,0 +1,123 @@
+package plugin
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// same separator as helm's releaseutil
+var manifestSep = regexp.MustCompile("(?:^|\\s*\n)---\\s*")
+
+// sourcePrefix starts the comment helm adds to every rendered resource
+const sourcePrefix = "# Source:"
+
+// SplitManifest split a rendered manifest to yaml documents, the order is kept and empty documents are dropped
+func SplitManifest(manifest string) []string {
+	var docs []string
+	for _, doc := range manifestSep.Split(manifest, -1) {
+		if isEmptyDoc(doc) {
+			continue
+		}
+		docs = append(docs, strings.TrimSpace(doc)+"\n")
+	}
+	return docs
+}
+
+// isEmptyDoc checks whether a yaml document contains nothing but comments
+func isEmptyDoc(doc string) bool {
+	for _, line := range strings.Split(doc, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return false
+		}
+	}
+	return true
+}
+
+// ManifestToList parse a rendered manifest to a list of objects
+func ManifestToList(manifest string) (*unstructured.UnstructuredList, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetAPIVersion("v1")
+	list.SetKind("List")
+
+	for _, doc := range SplitManifest(manifest) {
+		data, err := yaml.YAMLToJSON([]byte(doc))
+		if err != nil {
+			return nil, err
+		}
+
+		var obj unstructured.Unstructured
+		if err := obj.UnmarshalJSON(data); err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, obj)
+	}
+	return list, nil
+}
+
+// ManifestDoc is a single resource in a manifest
+type ManifestDoc struct {
+	// Source is the template the resource is rendered from
+	Source string
+	Kind   string
+	Name   string
+	// Namespace is empty if the resource doesn't set it
+	Namespace string
+	Content   string
+}
+
+// ParseManifest split a manifest to resources, the kind, name and namespace are read from each document
+func ParseManifest(manifest string) ([]ManifestDoc, error) {
+	var docs []ManifestDoc
+	for _, content := range SplitManifest(manifest) {
+		var head struct {
+			Kind     string `json:"kind"`
+			Metadata struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+		}
+		if err := yaml.Unmarshal([]byte(content), &head); err != nil {
+			return nil, err
+		}
+
+		doc := ManifestDoc{Kind: head.Kind, Name: head.Metadata.Name, Namespace: head.Metadata.Namespace, Content: content}
+		for _, line := range strings.Split(content, "\n") {
+			if strings.HasPrefix(line, sourcePrefix) {
+				doc.Source = strings.TrimSpace(strings.TrimPrefix(line, sourcePrefix))
+				break
+			}
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// FilterManifest returns the resources matching kind and name, the kind is case insensitive and
+// an empty kind or name matches all
+func FilterManifest(docs []ManifestDoc, kind, name string) []ManifestDoc {
+	var result []ManifestDoc
+	for _, doc := range docs {
+		if kind != "" && !strings.EqualFold(kind, doc.Kind) {
+			continue
+		}
+		if name != "" && name != doc.Name {
+			continue
+		}
+		result = append(result, doc)
+	}
+	return result
+}
+
+// JoinManifest join the resources back to a manifest
+func JoinManifest(docs []ManifestDoc) string {
+	var b strings.Builder
+	for _, doc := range docs {
+		b.WriteString("---\n")
+		b.WriteString(doc.Content)
+	}
+	return b.String()
+}
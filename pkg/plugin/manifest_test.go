@@ -0,0 +1,57 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/gsamokovarov/assert"
+)
+
+const testManifest = `---
+# Source: demo/templates/cm.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: foo
+---
+# Source: demo/templates/empty.yaml
+# nothing rendered
+---
+# Source: demo/templates/deploy.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: foo
+---
+# Source: demo/templates/svc.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: bar
+`
+
+func TestParseManifest(t *testing.T) {
+	docs, err := ParseManifest(testManifest)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(docs))
+
+	assert.Equal(t, ManifestDoc{
+		Source:  "demo/templates/cm.yaml",
+		Kind:    "ConfigMap",
+		Name:    "foo",
+		Content: "# Source: demo/templates/cm.yaml\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: foo\n",
+	}, docs[0])
+
+	filtered := FilterManifest(docs, "deployment", "")
+	assert.Equal(t, 1, len(filtered))
+	assert.Equal(t, "Deployment", filtered[0].Kind)
+
+	filtered = FilterManifest(docs, "", "foo")
+	assert.Equal(t, 2, len(filtered))
+
+	assert.Equal(t, 0, len(FilterManifest(docs, "Service", "foo")))
+
+	list, err := ManifestToList(JoinManifest(docs))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(list.Items))
+	assert.Equal(t, "bar", list.Items[2].GetName())
+}
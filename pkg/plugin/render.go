@@ -0,0 +1,136 @@
+package plugin
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/alauda/helm-crds/pkg/apis/app/v1alpha1"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"helm.sh/helm/pkg/chart"
+	"helm.sh/helm/pkg/chartutil"
+	"helm.sh/helm/pkg/engine"
+	"helm.sh/helm/pkg/release"
+	"helm.sh/helm/pkg/releaseutil"
+)
+
+const notesFileSuffix = "NOTES.txt"
+
+// Rendered is a chart rendered like helm install does
+type Rendered struct {
+	// Manifest are the resources in install order
+	Manifest string
+	Hooks    []*release.Hook
+	Notes    string
+}
+
+// Render render the chart with values as release name in namespace
+func Render(ch *chart.Chart, values map[string]interface{}, name, namespace string, isUpgrade bool) (*Rendered, error) {
+	options := chartutil.ReleaseOptions{
+		Name:      name,
+		Namespace: namespace,
+		IsUpgrade: isUpgrade,
+		IsInstall: !isUpgrade,
+	}
+	vals, err := chartutil.ToRenderValues(ch, values, options, chartutil.DefaultCapabilities)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := engine.Render(ch, vals)
+	if err != nil {
+		return nil, err
+	}
+
+	var result Rendered
+	// only the notes of the parent chart are shown, just like helm
+	for k, v := range files {
+		if strings.HasSuffix(k, notesFileSuffix) {
+			if k == path.Join(ch.Name(), "templates", notesFileSuffix) {
+				result.Notes = v
+			}
+			delete(files, k)
+		}
+	}
+
+	hooks, manifests, err := releaseutil.SortManifests(files, chartutil.DefaultCapabilities.APIVersions, releaseutil.InstallOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	for _, m := range manifests {
+		fmt.Fprintf(&b, "---\n# Source: %s\n%s\n", m.Name, m.Content)
+	}
+	result.Manifest = b.String()
+	result.Hooks = hooks
+	return &result, nil
+}
+
+// RedactedValue replace the values read from secrets when they are printed
+const RedactedValue = "<redacted>"
+
+// ResolveValues returns the values captain will install the helmrequest with, the values from ValuesFrom
+// are merged in order and the values in spec win. Missing optional sources are skipped.
+func (p *CaptainContext) ResolveValues(spec *v1alpha1.HelmRequestSpec) (map[string]interface{}, error) {
+	return p.resolveValues(spec, false)
+}
+
+// ResolveValuesRedacted is ResolveValues with the values from secrets replaced by RedactedValue, it's
+// safe to print but not to render with
+func (p *CaptainContext) ResolveValuesRedacted(spec *v1alpha1.HelmRequestSpec) (map[string]interface{}, error) {
+	return p.resolveValues(spec, true)
+}
+
+func (p *CaptainContext) resolveValues(spec *v1alpha1.HelmRequestSpec, redact bool) (map[string]interface{}, error) {
+	result := map[string]interface{}{}
+
+	for _, source := range spec.ValuesFrom {
+		ref := refOf(source)
+
+		var (
+			data     string
+			found    bool
+			optional bool
+		)
+		switch {
+		case source.ConfigMapKeyRef != nil:
+			optional = source.ConfigMapKeyRef.Optional != nil && *source.ConfigMapKeyRef.Optional
+			cm, err := p.GetConfigMap(ref.name)
+			if err == nil {
+				data, found = cm.Data[ref.key]
+			} else if !optional {
+				return nil, errors.Wrapf(err, "get configmap %s error", ref.name)
+			}
+		case source.SecretKeyRef != nil:
+			optional = source.SecretKeyRef.Optional != nil && *source.SecretKeyRef.Optional
+			secret, err := p.GetSecret(ref.name)
+			if err == nil {
+				var raw []byte
+				raw, found = secret.Data[ref.key]
+				data = string(raw)
+			} else if !optional {
+				return nil, errors.Wrapf(err, "get secret %s error", ref.name)
+			}
+		}
+
+		if !found {
+			if optional {
+				continue
+			}
+			return nil, fmt.Errorf("key %s not found in %s %s", ref.key, ref.kind, ref.name)
+		}
+
+		values := map[string]interface{}{}
+		if err := yaml.Unmarshal([]byte(data), &values); err != nil {
+			return nil, errors.Wrapf(err, "key %s in %s %s is not valid yaml", ref.key, ref.kind, ref.name)
+		}
+		if redact && source.SecretKeyRef != nil {
+			values = replaceLeaf(values, RedactedValue).(map[string]interface{})
+		}
+		result = MergeMaps(result, values)
+	}
+
+	return MergeMaps(result, spec.Values.AsMap()), nil
+}
@@ -0,0 +1,169 @@
+package plugin
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alauda/helm-crds/pkg/apis/app/v1alpha1"
+	"github.com/gsamokovarov/assert"
+	"helm.sh/helm/pkg/chart"
+	"helm.sh/helm/pkg/chartutil"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestChart() *chart.Chart {
+	return &chart.Chart{
+		Metadata: &chart.Metadata{Name: "demo", Version: "0.1.0", APIVersion: "v1"},
+		Values:   map[string]interface{}{"replicas": 1},
+		Templates: []*chart.File{
+			{Name: "templates/cm.yaml", Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .Release.Name }}\ndata:\n  replicas: \"{{ .Values.replicas }}\"\n")},
+			{Name: "templates/NOTES.txt", Data: []byte("installed {{ .Release.Name }}")},
+		},
+	}
+}
+
+func TestRender(t *testing.T) {
+	rendered, err := Render(newTestChart(), map[string]interface{}{"replicas": 3}, "foo", "default", true)
+	assert.Nil(t, err)
+
+	assert.True(t, strings.Contains(rendered.Manifest, "# Source: demo/templates/cm.yaml"))
+	assert.True(t, strings.Contains(rendered.Manifest, "name: foo"))
+	assert.True(t, strings.Contains(rendered.Manifest, `replicas: "3"`))
+	assert.Equal(t, "installed foo", rendered.Notes)
+}
+
+func TestResolveValues(t *testing.T) {
+	core := fake.NewSimpleClientset(
+		&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "base", Namespace: "default"},
+			Data:       map[string]string{"values.yaml": "replicas: 2\nimage:\n  tag: v1\n"},
+		},
+	)
+	p := &CaptainContext{core: core, namespace: "default"}
+
+	var spec v1alpha1.HelmRequestSpec
+	spec.ValuesFrom = []v1alpha1.ValuesFromSource{
+		mustValuesFrom(t, "configmap:base", false),
+		mustValuesFrom(t, "secret:missing", true),
+	}
+	spec.Values = chartutil.Values{"image": map[string]interface{}{"tag": "v2"}}
+
+	values, err := p.ResolveValues(&spec)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"replicas": float64(2),
+		"image":    map[string]interface{}{"tag": "v2"},
+	}, values)
+
+	spec.ValuesFrom = append(spec.ValuesFrom, mustValuesFrom(t, "secret:missing", false))
+	_, err = p.ResolveValues(&spec)
+	assert.NotNil(t, err)
+}
+
+func TestResolveValuesRedacted(t *testing.T) {
+	core := fake.NewSimpleClientset(
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: "default"},
+			Data:       map[string][]byte{"values.yaml": []byte("db:\n  password: secret\n  hosts: [a, b]\n")},
+		},
+	)
+	p := &CaptainContext{core: core, namespace: "default"}
+
+	var spec v1alpha1.HelmRequestSpec
+	spec.ValuesFrom = []v1alpha1.ValuesFromSource{mustValuesFrom(t, "secret:creds", false)}
+	spec.Values = chartutil.Values{"db": map[string]interface{}{"user": "admin"}}
+
+	values, err := p.ResolveValuesRedacted(&spec)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"db": map[string]interface{}{
+			"password": RedactedValue,
+			"hosts":    []interface{}{RedactedValue, RedactedValue},
+			"user":     "admin",
+		},
+	}, values)
+
+	values, err = p.ResolveValues(&spec)
+	assert.Nil(t, err)
+	assert.Equal(t, "secret", values["db"].(map[string]interface{})["password"])
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	diff, err := DiffYAML("a", "b", map[string]interface{}{"a": 1, "b": 2}, map[string]interface{}{"a": 1, "b": 3})
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(diff, "-b: 2\n+b: 3\n"))
+
+	diff, err = DiffYAML("a", "b", map[string]interface{}{"a": 1}, map[string]interface{}{"a": 1})
+	assert.Nil(t, err)
+	assert.Equal(t, "", diff)
+}
+
+func TestDiffManifests(t *testing.T) {
+	a := `---
+kind: ConfigMap
+metadata:
+  name: foo
+data:
+  a: "1"
+---
+kind: Service
+metadata:
+  name: foo
+`
+	b := `---
+kind: Deployment
+metadata:
+  name: foo
+---
+kind: ConfigMap
+metadata:
+  name: foo
+data:
+  a: "2"
+`
+	diff, err := DiffManifests("1", "2", a, b)
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(diff, "--- 1/configmap/foo\n+++ 2/configmap/foo\n"))
+	assert.True(t, strings.Contains(diff, "-  a: \"1\"\n+  a: \"2\"\n"))
+	assert.True(t, strings.Contains(diff, "--- 1/service/foo\n"))
+	assert.True(t, strings.Contains(diff, "+++ 2/deployment/foo\n"))
+
+	// the order of the resources does not matter
+	reordered := `---
+kind: Service
+metadata:
+  name: foo
+---
+kind: ConfigMap
+metadata:
+  name: foo
+data:
+  a: "1"
+`
+	diff, err = DiffManifests("1", "2", a, reordered)
+	assert.Nil(t, err)
+	assert.Equal(t, "", diff)
+
+	// the same kind and name in different namespaces are different resources
+	namespaced := `---
+kind: ConfigMap
+metadata:
+  name: foo
+  namespace: a
+---
+kind: ConfigMap
+metadata:
+  name: foo
+  namespace: b
+`
+	diff, err = DiffManifests("1", "2", namespaced, namespaced)
+	assert.Nil(t, err)
+	assert.Equal(t, "", diff)
+	diff, err = DiffManifests("1", "2", namespaced, strings.Replace(namespaced, "namespace: b", "namespace: c", 1))
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(diff, "--- 1/configmap/b/foo\n"))
+	assert.True(t, strings.Contains(diff, "+++ 2/configmap/c/foo\n"))
+	assert.False(t, strings.Contains(diff, "configmap/a/foo"))
+}
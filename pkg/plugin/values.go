@@ -0,0 +1,154 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"helm.sh/helm/pkg/strvals"
+)
+
+// ValueOptions are the values given on the command line, they are merged in helm's order: the values
+// files, then --set, --set-string and --set-file, latter ones win
+type ValueOptions struct {
+	// ValueFiles are local paths of yaml files, '-' means stdin
+	ValueFiles []string
+	// Values are the --set key=value pairs
+	Values []string
+	// StringValues are the --set-string key=value pairs
+	StringValues []string
+	// FileValues are the --set-file key=path pairs, the file content is used as the value
+	FileValues []string
+}
+
+// MergeValues merge the values into base, which is modified and returned. stdin is read for the '-' values file
+func (v *ValueOptions) MergeValues(base map[string]interface{}, stdin io.Reader) (map[string]interface{}, error) {
+	if base == nil {
+		base = map[string]interface{}{}
+	}
+
+	for _, filePath := range v.ValueFiles {
+		var data []byte
+		var err error
+		if strings.TrimSpace(filePath) == "-" {
+			data, err = ioutil.ReadAll(stdin)
+		} else {
+			data, err = ioutil.ReadFile(filePath)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", filePath)
+		}
+
+		current := map[string]interface{}{}
+		if err := yaml.Unmarshal(data, &current); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse %s", filePath)
+		}
+		base = MergeMaps(base, current)
+	}
+
+	for _, value := range v.Values {
+		if err := strvals.ParseInto(value, base); err != nil {
+			return nil, errors.Wrap(err, "failed parsing --set data")
+		}
+	}
+
+	for _, value := range v.StringValues {
+		if err := strvals.ParseIntoString(value, base); err != nil {
+			return nil, errors.Wrap(err, "failed parsing --set-string data")
+		}
+	}
+
+	for _, value := range v.FileValues {
+		current, err := parseFileValue(value)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed parsing --set-file data")
+		}
+		base = MergeMaps(base, current)
+	}
+
+	return base, nil
+}
+
+// parseFileValue parse a key=path pair, the file content is set to key as a string
+func parseFileValue(s string) (map[string]interface{}, error) {
+	splits := strings.SplitN(s, "=", 2)
+	if len(splits) != 2 || splits[0] == "" {
+		return nil, fmt.Errorf("key %q has no value", s)
+	}
+
+	content, err := ioutil.ReadFile(splits[1])
+	if err != nil {
+		return nil, err
+	}
+
+	// let strvals parse the key, so the same key syntax as --set is supported, the placeholder value
+	// is replaced by the content then, as the content may contains any of strvals's separators
+	result, err := strvals.ParseString(splits[0] + "=")
+	if err != nil {
+		return nil, err
+	}
+	return replaceLeaf(result, string(content)).(map[string]interface{}), nil
+}
+
+// replaceLeaf replace every leaf value of v, a parsed single key has only one
+func replaceLeaf(v interface{}, value string) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			t[k] = replaceLeaf(child, value)
+		}
+		return t
+	case []interface{}:
+		for i, child := range t {
+			if child != nil {
+				t[i] = replaceLeaf(child, value)
+			}
+		}
+		return t
+	}
+	return value
+}
+
+// MergeMaps merge b into a recursively, values in b win
+func MergeMaps(a, b map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(a))
+	for k, v := range a {
+		out[k] = v
+	}
+	for k, v := range b {
+		if v, ok := v.(map[string]interface{}); ok {
+			if bv, ok := out[k]; ok {
+				if bv, ok := bv.(map[string]interface{}); ok {
+					out[k] = MergeMaps(bv, v)
+					continue
+				}
+			}
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// UnsetValue delete the key at path from values, path is dot separated like --set, eg: image.tag.
+// It returns false if the key does not exist.
+func UnsetValue(values map[string]interface{}, path string) bool {
+	keys := strings.Split(path, ".")
+	current := values
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := current[key].(map[string]interface{})
+		if !ok {
+			return false
+		}
+		current = next
+	}
+
+	last := keys[len(keys)-1]
+	if _, ok := current[last]; !ok {
+		return false
+	}
+	delete(current, last)
+	return true
+}
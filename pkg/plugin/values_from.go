@@ -0,0 +1,205 @@
+package plugin
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alauda/helm-crds/pkg/apis/app/v1alpha1"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// DefaultValuesKey is the key used when a values-from reference does not specify one
+const DefaultValuesKey = "values.yaml"
+
+// ValuesFromOptions change the ValuesFrom of a helmrequest, references are in the format of
+// configmap:<name>[:key] or secret:<name>[:key]
+type ValuesFromOptions struct {
+	// Add are the references to append, an existing one with the same kind, name and key is updated in place
+	Add []string
+	// Remove are the references to remove, a reference without key removes all keys of the object
+	Remove []string
+	// Replace drop all existing sources before adding
+	Replace bool
+	// Optional mark the added sources as optional, captain ignores them if they don't exist
+	Optional bool
+}
+
+type valuesFromRef struct {
+	kind string
+	name string
+	key  string
+}
+
+func parseValuesFromRef(s string) (valuesFromRef, error) {
+	splits := strings.Split(s, ":")
+	if len(splits) < 2 || len(splits) > 3 || splits[1] == "" {
+		return valuesFromRef{}, fmt.Errorf("invalid values-from %q, the format is configmap|secret:<name>[:key]", s)
+	}
+
+	ref := valuesFromRef{kind: strings.ToLower(splits[0]), name: splits[1]}
+	switch ref.kind {
+	case "configmap", "cm":
+		ref.kind = "configmap"
+	case "secret":
+	default:
+		return valuesFromRef{}, fmt.Errorf("invalid values-from %q, only configmap and secret are supported", s)
+	}
+	if len(splits) == 3 {
+		ref.key = splits[2]
+	}
+	return ref, nil
+}
+
+func refOf(source v1alpha1.ValuesFromSource) valuesFromRef {
+	switch {
+	case source.ConfigMapKeyRef != nil:
+		return valuesFromRef{kind: "configmap", name: source.ConfigMapKeyRef.Name, key: source.ConfigMapKeyRef.Key}
+	case source.SecretKeyRef != nil:
+		return valuesFromRef{kind: "secret", name: source.SecretKeyRef.Name, key: source.SecretKeyRef.Key}
+	}
+	return valuesFromRef{}
+}
+
+// matches reports whether source is referenced by ref, an empty key of ref matches any key
+func (ref valuesFromRef) matches(source v1alpha1.ValuesFromSource) bool {
+	other := refOf(source)
+	return ref.kind == other.kind && ref.name == other.name && (ref.key == "" || ref.key == other.key)
+}
+
+func (ref valuesFromRef) source(optional bool) v1alpha1.ValuesFromSource {
+	key := ref.key
+	if key == "" {
+		key = DefaultValuesKey
+	}
+
+	if ref.kind == "secret" {
+		return v1alpha1.ValuesFromSource{
+			SecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: ref.name},
+				Key:                  key,
+				Optional:             &optional,
+			},
+		}
+	}
+	return v1alpha1.ValuesFromSource{
+		ConfigMapKeyRef: &v1.ConfigMapKeySelector{
+			LocalObjectReference: v1.LocalObjectReference{Name: ref.name},
+			Key:                  key,
+			Optional:             &optional,
+		},
+	}
+}
+
+// ParseValuesFrom parse a reference in the format of configmap|secret:<name>[:key], the key defaults to values.yaml
+func ParseValuesFrom(s string, optional bool) (v1alpha1.ValuesFromSource, error) {
+	ref, err := parseValuesFromRef(s)
+	if err != nil {
+		return v1alpha1.ValuesFromSource{}, err
+	}
+	return ref.source(optional), nil
+}
+
+// Apply returns the new sources after replace, remove and add, and the added ones for checking.
+// sources itself is not modified.
+func (o *ValuesFromOptions) Apply(sources []v1alpha1.ValuesFromSource) (result, added []v1alpha1.ValuesFromSource, err error) {
+	if !o.Replace {
+		result = append(result, sources...)
+	}
+
+	for _, s := range o.Remove {
+		ref, err := parseValuesFromRef(s)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var kept []v1alpha1.ValuesFromSource
+		for _, source := range result {
+			if !ref.matches(source) {
+				kept = append(kept, source)
+			}
+		}
+		if len(kept) == len(result) {
+			return nil, nil, fmt.Errorf("values-from %q not found", s)
+		}
+		result = kept
+	}
+
+	for _, s := range o.Add {
+		source, err := ParseValuesFrom(s, o.Optional)
+		if err != nil {
+			return nil, nil, err
+		}
+		added = append(added, source)
+
+		ref, replaced := refOf(source), false
+		for i := range result {
+			if ref.matches(result[i]) {
+				result[i] = source
+				replaced = true
+			}
+		}
+		if !replaced {
+			result = append(result, source)
+		}
+	}
+
+	return result, added, nil
+}
+
+// CheckValuesFrom verify the referenced key exists in the working namespace and is valid yaml.
+// A missing optional source is fine, captain will ignore it.
+func (p *CaptainContext) CheckValuesFrom(source v1alpha1.ValuesFromSource) error {
+	ref := refOf(source)
+	optional := false
+
+	var (
+		data  string
+		found bool
+	)
+	switch {
+	case source.ConfigMapKeyRef != nil:
+		if source.ConfigMapKeyRef.Optional != nil {
+			optional = *source.ConfigMapKeyRef.Optional
+		}
+		cm, err := p.GetConfigMap(ref.name)
+		if err != nil {
+			if apierrors.IsNotFound(err) && optional {
+				return nil
+			}
+			return errors.Wrapf(err, "get configmap %s error", ref.name)
+		}
+		data, found = cm.Data[ref.key]
+	case source.SecretKeyRef != nil:
+		if source.SecretKeyRef.Optional != nil {
+			optional = *source.SecretKeyRef.Optional
+		}
+		secret, err := p.GetSecret(ref.name)
+		if err != nil {
+			if apierrors.IsNotFound(err) && optional {
+				return nil
+			}
+			return errors.Wrapf(err, "get secret %s error", ref.name)
+		}
+		var raw []byte
+		raw, found = secret.Data[ref.key]
+		data = string(raw)
+	default:
+		return errors.New("empty values-from source")
+	}
+
+	if !found {
+		if optional {
+			return nil
+		}
+		return fmt.Errorf("key %s not found in %s %s", ref.key, ref.kind, ref.name)
+	}
+
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(data), &values); err != nil {
+		return errors.Wrapf(err, "key %s in %s %s is not valid yaml", ref.key, ref.kind, ref.name)
+	}
+	return nil
+}
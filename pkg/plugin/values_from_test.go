@@ -0,0 +1,126 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/alauda/helm-crds/pkg/apis/app/v1alpha1"
+	"github.com/gsamokovarov/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func mustValuesFrom(t *testing.T, s string, optional bool) v1alpha1.ValuesFromSource {
+	source, err := ParseValuesFrom(s, optional)
+	assert.Nil(t, err)
+	return source
+}
+
+func TestParseValuesFrom(t *testing.T) {
+	source := mustValuesFrom(t, "configmap:foo", false)
+	assert.Equal(t, "foo", source.ConfigMapKeyRef.Name)
+	assert.Equal(t, DefaultValuesKey, source.ConfigMapKeyRef.Key)
+	assert.Nil(t, source.SecretKeyRef)
+
+	source = mustValuesFrom(t, "secret:bar:prod.yaml", true)
+	assert.Equal(t, "bar", source.SecretKeyRef.Name)
+	assert.Equal(t, "prod.yaml", source.SecretKeyRef.Key)
+	assert.True(t, *source.SecretKeyRef.Optional)
+
+	for _, s := range []string{"foo", "configmap:", "pod:foo", "secret:a:b:c"} {
+		_, err := ParseValuesFrom(s, false)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestApplyValuesFrom(t *testing.T) {
+	existing := []v1alpha1.ValuesFromSource{
+		mustValuesFrom(t, "configmap:base", false),
+		mustValuesFrom(t, "secret:creds:a.yaml", false),
+		mustValuesFrom(t, "secret:creds:b.yaml", false),
+	}
+
+	tests := []struct {
+		name    string
+		opts    ValuesFromOptions
+		want    []v1alpha1.ValuesFromSource
+		wantErr bool
+	}{
+		{
+			name: "append",
+			opts: ValuesFromOptions{Add: []string{"configmap:extra"}},
+			want: append(existing[:3:3], mustValuesFrom(t, "configmap:extra", false)),
+		},
+		{
+			name: "update in place",
+			opts: ValuesFromOptions{Add: []string{"configmap:base"}, Optional: true},
+			want: []v1alpha1.ValuesFromSource{mustValuesFrom(t, "configmap:base", true), existing[1], existing[2]},
+		},
+		{
+			name: "remove all keys",
+			opts: ValuesFromOptions{Remove: []string{"secret:creds"}},
+			want: existing[:1],
+		},
+		{
+			name: "remove one key",
+			opts: ValuesFromOptions{Remove: []string{"secret:creds:a.yaml"}},
+			want: []v1alpha1.ValuesFromSource{existing[0], existing[2]},
+		},
+		{
+			name:    "remove not found",
+			opts:    ValuesFromOptions{Remove: []string{"configmap:nope"}},
+			wantErr: true,
+		},
+		{
+			name: "replace",
+			opts: ValuesFromOptions{Add: []string{"secret:only"}, Replace: true},
+			want: []v1alpha1.ValuesFromSource{mustValuesFrom(t, "secret:only", false)},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			result, _, err := tt.opts.Apply(existing)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, result)
+		})
+	}
+}
+
+func TestCheckValuesFrom(t *testing.T) {
+	core := fake.NewSimpleClientset(
+		&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "good", Namespace: "default"},
+			Data:       map[string]string{"values.yaml": "a: b\n", "bad.yaml": "a: [b"},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: "default"},
+			Data:       map[string][]byte{"values.yaml": []byte("password: x\n")},
+		},
+	)
+	p := &CaptainContext{core: core, namespace: "default"}
+
+	tests := []struct {
+		ref      string
+		optional bool
+		wantErr  bool
+	}{
+		{"configmap:good", false, false},
+		{"secret:creds", false, false},
+		{"configmap:good:bad.yaml", false, true},
+		{"configmap:good:missing.yaml", false, true},
+		{"configmap:good:missing.yaml", true, false},
+		{"secret:missing", false, true},
+		{"secret:missing", true, false},
+	}
+
+	for _, tt := range tests {
+		err := p.CheckValuesFrom(mustValuesFrom(t, tt.ref, tt.optional))
+		assert.Equal(t, tt.wantErr, err != nil)
+	}
+}
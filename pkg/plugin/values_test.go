@@ -0,0 +1,83 @@
+package plugin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gsamokovarov/assert"
+)
+
+func TestMergeValues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "values")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	opts := ValueOptions{
+		ValueFiles: []string{
+			write("base.yaml", "image:\n  tag: v1\n  pullPolicy: Always\nreplicas: 1\n"),
+			"-",
+		},
+		Values:       []string{"replicas=3,image.tag=v3"},
+		StringValues: []string{"version=1.10"},
+		FileValues:   []string{"config.data=" + write("config", "a=b,c=d\n")},
+	}
+
+	base := map[string]interface{}{"existing": true, "replicas": 0}
+	values, err := opts.MergeValues(base, strings.NewReader("image:\n  tag: v2\n"))
+	assert.Nil(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"existing": true,
+		"image": map[string]interface{}{
+			"tag":        "v3",
+			"pullPolicy": "Always",
+		},
+		"replicas": int64(3),
+		"version":  "1.10",
+		"config": map[string]interface{}{
+			"data": "a=b,c=d\n",
+		},
+	}, values)
+}
+
+func TestMergeValuesErrors(t *testing.T) {
+	_, err := (&ValueOptions{ValueFiles: []string{"-"}}).MergeValues(nil, strings.NewReader("a: [b"))
+	assert.NotNil(t, err)
+
+	_, err = (&ValueOptions{FileValues: []string{"a"}}).MergeValues(nil, nil)
+	assert.NotNil(t, err)
+
+	_, err = (&ValueOptions{Values: []string{"a"}}).MergeValues(nil, nil)
+	assert.NotNil(t, err)
+}
+
+func TestUnsetValue(t *testing.T) {
+	values := map[string]interface{}{
+		"image": map[string]interface{}{
+			"tag":        "v1",
+			"pullPolicy": "Always",
+		},
+		"replicas": 1,
+	}
+
+	assert.True(t, UnsetValue(values, "image.tag"))
+	assert.True(t, UnsetValue(values, "replicas"))
+	assert.False(t, UnsetValue(values, "image.tag"))
+	assert.False(t, UnsetValue(values, "replicas.count"))
+	assert.False(t, UnsetValue(values, "resources.limits"))
+
+	assert.Equal(t, map[string]interface{}{
+		"image": map[string]interface{}{
+			"pullPolicy": "Always",
+		},
+	}, values)
+}
@@ -0,0 +1,317 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alauda/helm-crds/pkg/apis/app/v1alpha1"
+	"github.com/alauda/helm-crds/pkg/apis/app/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ErrWaitFailed means the object stayed in Failed phase longer than the failure grace period
+var ErrWaitFailed = errors.New("failed, please check it's events to find out why")
+
+// ErrWaitDeleted means the object was deleted while waiting for it to be synced
+var ErrWaitDeleted = errors.New("deleted while waiting for it")
+
+// WaitState is the state of a watched object
+type WaitState int
+
+const (
+	// WaitPending means keep waiting
+	WaitPending WaitState = iota
+	// WaitDone means the wait is done
+	WaitDone
+	// WaitFailed means the object failed, captain may still retry it
+	WaitFailed
+	// WaitDeleted means the object is gone and will never be done
+	WaitDeleted
+)
+
+// WaitOptions controls when a wait ends
+type WaitOptions struct {
+	// Timeout of the whole wait, 0 means wait forever
+	Timeout time.Duration
+	// FailureGrace is how long the Failed phase is tolerated before the wait fails, because
+	// captain will retry in the background and it will succeed mostly
+	FailureGrace time.Duration
+	// ResourceVersion is the version of the object we just wrote, if set, the phase observed at
+	// this version is a stale one and ignored until captain writes the object
+	ResourceVersion string
+}
+
+// CheckFunc map a watched object to a WaitState, obj is nil if the object does not exist
+type CheckFunc func(obj runtime.Object) WaitState
+
+// HelmRequestSynced is the CheckFunc for a helmrequest to be synced
+func HelmRequestSynced(obj runtime.Object) WaitState {
+	hr, ok := obj.(*v1alpha1.HelmRequest)
+	if !ok {
+		return WaitDeleted
+	}
+	switch hr.Status.Phase {
+	case v1alpha1.HelmRequestSynced:
+		return WaitDone
+	case v1alpha1.HelmRequestFailed:
+		return WaitFailed
+	}
+	return WaitPending
+}
+
+// HelmRequestDeleted returns the CheckFunc for a deleted helmrequest to be gone. resourceVersion is the
+// version observed right after the delete, the Failed phase is a stale one until captain has written the
+// helmrequest being deleted, after that it means captain failed to uninstall the release.
+func HelmRequestDeleted(resourceVersion string) CheckFunc {
+	return func(obj runtime.Object) WaitState {
+		hr, ok := obj.(*v1alpha1.HelmRequest)
+		if !ok {
+			return WaitDone
+		}
+		if hr.DeletionTimestamp == nil || hr.ResourceVersion == resourceVersion {
+			return WaitPending
+		}
+		if hr.Status.Phase == v1alpha1.HelmRequestFailed {
+			return WaitFailed
+		}
+		return WaitPending
+	}
+}
+
+// ChartRepoSynced is the CheckFunc for a chartrepo to be synced
+func ChartRepoSynced(obj runtime.Object) WaitState {
+	repo, ok := obj.(*v1beta1.ChartRepo)
+	if !ok {
+		return WaitDeleted
+	}
+	switch repo.Status.Phase {
+	case v1beta1.ChartRepoSynced:
+		return WaitDone
+	case v1beta1.ChartRepoFailed:
+		return WaitFailed
+	}
+	return WaitPending
+}
+
+// WaitHelmRequest watch the helmrequest in the working namespace until check is done or failed
+func (p *CaptainContext) WaitHelmRequest(name string, opts WaitOptions, check CheckFunc) (*v1alpha1.HelmRequest, error) {
+	client := p.cli.AppV1alpha1().HelmRequests(p.namespace)
+	lw := nameListWatch(name,
+		func(options metav1.ListOptions) (runtime.Object, error) { return client.List(options) },
+		func(options metav1.ListOptions) (watch.Interface, error) { return client.Watch(options) })
+
+	obj, err := waitFor(lw, &v1alpha1.HelmRequest{}, opts, check)
+	hr, _ := obj.(*v1alpha1.HelmRequest)
+	return hr, err
+}
+
+// WaitChartRepo watch the chartrepo until check is done or failed
+func (p *CaptainContext) WaitChartRepo(name, namespace string, opts WaitOptions, check CheckFunc) (*v1beta1.ChartRepo, error) {
+	client := p.cli.AppV1beta1().ChartRepos(namespace)
+	lw := nameListWatch(name,
+		func(options metav1.ListOptions) (runtime.Object, error) { return client.List(options) },
+		func(options metav1.ListOptions) (watch.Interface, error) { return client.Watch(options) })
+
+	obj, err := waitFor(lw, &v1beta1.ChartRepo{}, opts, check)
+	repo, _ := obj.(*v1beta1.ChartRepo)
+	return repo, err
+}
+
+// WaitReleasesDeleted watch the Release resources of a release until all of them are gone
+func (p *CaptainContext) WaitReleasesDeleted(name, namespace string, opts WaitOptions) error {
+	client := p.cli.AppV1alpha1().Releases(namespace)
+	selector := fmt.Sprintf("name=%s", name)
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.LabelSelector = selector
+			return client.List(options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.LabelSelector = selector
+			return client.Watch(options)
+		},
+	}
+	return waitForEmpty(lw, &v1alpha1.Release{}, opts.Timeout)
+}
+
+// nameListWatch list and watch a single object by it's name
+func nameListWatch(name string, listFunc cache.ListFunc, watchFunc cache.WatchFunc) *cache.ListWatch {
+	selector := fields.OneTermEqualSelector("metadata.name", name).String()
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = selector
+			return listFunc(options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = selector
+			return watchFunc(options)
+		},
+	}
+}
+
+// waitFor run an informer on lw and feed every observed object to check. The informer's reflector
+// takes care of relisting when the watch expires or the connection breaks.
+// The last observed object is returned, it's nil if the object never showed up or has been deleted.
+func waitFor(lw cache.ListerWatcher, objType runtime.Object, opts WaitOptions, check CheckFunc) (runtime.Object, error) {
+	stop := make(chan struct{})
+	defer close(stop)
+
+	updates := make(chan runtime.Object)
+	notify := func(obj runtime.Object) {
+		select {
+		case updates <- obj:
+		case <-stop:
+		}
+	}
+
+	store, controller := cache.NewInformer(lw, objType, 0, cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			notify(obj.(runtime.Object))
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			notify(obj.(runtime.Object))
+		},
+		DeleteFunc: func(obj interface{}) {
+			notify(nil)
+		},
+	})
+	go controller.Run(stop)
+
+	// the object may not exist at all, tell check about it after the initial list
+	synced := make(chan struct{})
+	go func() {
+		if cache.WaitForCacheSync(stop, controller.HasSynced) {
+			close(synced)
+		}
+	}()
+
+	var timeout <-chan time.Time
+	if opts.Timeout > 0 {
+		timer := time.NewTimer(opts.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var (
+		last   runtime.Object
+		grace  *time.Timer
+		graceC <-chan time.Time
+		// trusted is false while the object is still the one we wrote
+		trusted = opts.ResourceVersion == ""
+	)
+	defer func() {
+		if grace != nil {
+			grace.Stop()
+		}
+	}()
+
+	// observe return true when the wait should end
+	observe := func(obj runtime.Object) (bool, error) {
+		last = obj
+		if !trusted && obj != nil {
+			accessor, err := meta.Accessor(obj)
+			if err == nil && accessor.GetResourceVersion() == opts.ResourceVersion {
+				return false, nil
+			}
+			trusted = true
+		}
+
+		switch check(obj) {
+		case WaitDone:
+			return true, nil
+		case WaitDeleted:
+			return true, ErrWaitDeleted
+		case WaitFailed:
+			if opts.FailureGrace <= 0 {
+				return true, ErrWaitFailed
+			}
+			if grace == nil {
+				grace = time.NewTimer(opts.FailureGrace)
+				graceC = grace.C
+			}
+		default:
+			if grace != nil {
+				grace.Stop()
+				grace, graceC = nil, nil
+			}
+		}
+		return false, nil
+	}
+
+	for {
+		var (
+			done bool
+			err  error
+		)
+
+		select {
+		case obj := <-updates:
+			done, err = observe(obj)
+		case <-synced:
+			synced = nil
+			if len(store.List()) == 0 {
+				done, err = observe(nil)
+			}
+		case <-graceC:
+			return last, ErrWaitFailed
+		case <-timeout:
+			return last, wait.ErrWaitTimeout
+		}
+
+		if done {
+			return last, err
+		}
+	}
+}
+
+// waitForEmpty run an informer on lw until no object is left, a timeout of 0 means wait forever
+func waitForEmpty(lw cache.ListerWatcher, objType runtime.Object, timeout time.Duration) error {
+	stop := make(chan struct{})
+	defer close(stop)
+
+	deleted := make(chan struct{}, 1)
+	store, controller := cache.NewInformer(lw, objType, 0, cache.ResourceEventHandlerFuncs{
+		DeleteFunc: func(obj interface{}) {
+			select {
+			case deleted <- struct{}{}:
+			default:
+			}
+		},
+	})
+	go controller.Run(stop)
+
+	synced := make(chan struct{})
+	go func() {
+		if cache.WaitForCacheSync(stop, controller.HasSynced) {
+			close(synced)
+		}
+	}()
+
+	var timeoutC <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutC = timer.C
+	}
+
+	hasSynced := false
+	for {
+		select {
+		case <-synced:
+			synced, hasSynced = nil, true
+		case <-deleted:
+		case <-timeoutC:
+			return wait.ErrWaitTimeout
+		}
+		if hasSynced && len(store.List()) == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alauda/helm-crds/pkg/apis/app/v1alpha1"
+	"github.com/alauda/helm-crds/pkg/client/clientset/versioned/fake"
+	"github.com/gsamokovarov/assert"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	corefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func newHelmRequest(name string, phase v1alpha1.HelmRequestPhase) *v1alpha1.HelmRequest {
+	var hr v1alpha1.HelmRequest
+	hr.Name = name
+	hr.Namespace = "default"
+	hr.Status.Phase = phase
+	return &hr
+}
+
+func TestWaitHelmRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		phases  []v1alpha1.HelmRequestPhase
+		opts    WaitOptions
+		phase   v1alpha1.HelmRequestPhase
+		wantErr error
+	}{
+		{"synced", []v1alpha1.HelmRequestPhase{"Pending", "Synced"}, WaitOptions{}, "Synced", nil},
+		{"failed", []v1alpha1.HelmRequestPhase{"Pending", "Failed"}, WaitOptions{}, "Failed", ErrWaitFailed},
+		{"recovered in grace", []v1alpha1.HelmRequestPhase{"Failed", "Pending", "Synced"}, WaitOptions{FailureGrace: time.Minute}, "Synced", nil},
+		{"failed after grace", []v1alpha1.HelmRequestPhase{"Pending", "Failed"}, WaitOptions{FailureGrace: 100 * time.Millisecond}, "Failed", ErrWaitFailed},
+		{"timeout", []v1alpha1.HelmRequestPhase{"Pending"}, WaitOptions{Timeout: 100 * time.Millisecond}, "Pending", wait.ErrWaitTimeout},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cli := fake.NewSimpleClientset(newHelmRequest("foo", tt.phases[0]))
+			p := &CaptainContext{cli: cli, namespace: "default"}
+
+			go func() {
+				for _, phase := range tt.phases[1:] {
+					time.Sleep(20 * time.Millisecond)
+					_, _ = cli.AppV1alpha1().HelmRequests("default").Update(newHelmRequest("foo", phase))
+				}
+			}()
+
+			hr, err := p.WaitHelmRequest("foo", tt.opts, HelmRequestSynced)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.phase, hr.Status.Phase)
+		})
+	}
+}
+
+func TestWaitHelmRequestWritten(t *testing.T) {
+	// the Synced phase at the version we wrote is left from before our change
+	stale := newHelmRequest("foo", v1alpha1.HelmRequestSynced)
+	stale.ResourceVersion = "5"
+	cli := fake.NewSimpleClientset(stale)
+	p := &CaptainContext{cli: cli, namespace: "default"}
+
+	opts := WaitOptions{Timeout: 100 * time.Millisecond, ResourceVersion: "5"}
+	_, err := p.WaitHelmRequest("foo", opts, HelmRequestSynced)
+	assert.Equal(t, wait.ErrWaitTimeout, err)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		synced := stale.DeepCopy()
+		synced.ResourceVersion = "6"
+		_, _ = cli.AppV1alpha1().HelmRequests("default").Update(synced)
+	}()
+	opts.Timeout = time.Second
+	hr, err := p.WaitHelmRequest("foo", opts, HelmRequestSynced)
+	assert.Nil(t, err)
+	assert.Equal(t, "6", hr.ResourceVersion)
+
+	// deleted while waiting
+	opts.ResourceVersion = "6"
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_ = p.DeleteHelmRequest("foo")
+	}()
+	_, err = p.WaitHelmRequest("foo", opts, HelmRequestSynced)
+	assert.Equal(t, ErrWaitDeleted, err)
+}
+
+func TestFailureReason(t *testing.T) {
+	events := []v1.Event{
+		{Type: v1.EventTypeWarning, Message: "chart version not found"},
+		{Type: v1.EventTypeWarning, Message: "install failed: timeout\n"},
+		{Type: v1.EventTypeNormal, Message: "retrying"},
+	}
+	assert.Equal(t, "install failed: timeout", FailureReason(events))
+	assert.Equal(t, "", FailureReason(events[2:]))
+}
+
+func TestLastSyncTime(t *testing.T) {
+	now := time.Now()
+	events := []v1.Event{
+		{Type: v1.EventTypeNormal, Reason: SyncedReason, LastTimestamp: metav1.NewTime(now.Add(-time.Hour))},
+		{Type: v1.EventTypeNormal, Reason: SyncedReason, LastTimestamp: metav1.NewTime(now.Add(-time.Minute))},
+		{Type: v1.EventTypeNormal, Reason: "Deleting", LastTimestamp: metav1.NewTime(now.Add(-time.Second))},
+		{Type: v1.EventTypeWarning, LastTimestamp: metav1.NewTime(now)},
+	}
+	assert.Equal(t, now.Add(-time.Minute).Unix(), LastSyncTime(events).Unix())
+	assert.True(t, LastSyncTime(events[2:]).IsZero())
+}
+
+func TestGetEventsByObject(t *testing.T) {
+	now := time.Now()
+	newEvent := func(name, uid string, at time.Time) *v1.Event {
+		var event v1.Event
+		event.Name = name
+		event.Namespace = "alauda-system"
+		event.InvolvedObject = v1.ObjectReference{Kind: "ChartRepo", UID: types.UID(uid)}
+		event.LastTimestamp = metav1.NewTime(at)
+		return &event
+	}
+	core := corefake.NewSimpleClientset(
+		newEvent("b", "stable", now),
+		newEvent("a", "stable", now.Add(-time.Minute)),
+		newEvent("c", "incubator", now),
+	)
+	p := &CaptainContext{core: core}
+
+	grouped, err := p.GetEventsByObject("", "ChartRepo")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(grouped))
+	assert.Equal(t, 2, len(grouped["stable"]))
+	assert.Equal(t, "a", grouped["stable"][0].Name)
+	assert.Equal(t, "c", grouped["incubator"][0].Name)
+}
+
+func TestWaitHelmRequestDeleted(t *testing.T) {
+	cli := fake.NewSimpleClientset(newHelmRequest("foo", "Synced"))
+	p := &CaptainContext{cli: cli, namespace: "default"}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_ = p.DeleteHelmRequest("foo")
+	}()
+
+	hr, err := p.WaitHelmRequest("foo", WaitOptions{Timeout: time.Second}, HelmRequestDeleted("1"))
+	assert.Nil(t, err)
+	assert.Nil(t, hr)
+
+	// the Failed phase left before the delete is ignored
+	stale := newHelmRequest("foo", "Failed")
+	stale.ResourceVersion = "2"
+	now := metav1.Now()
+	stale.DeletionTimestamp = &now
+	cli = fake.NewSimpleClientset(stale)
+	p = &CaptainContext{cli: cli, namespace: "default"}
+	hr, err = p.WaitHelmRequest("foo", WaitOptions{Timeout: 100 * time.Millisecond}, HelmRequestDeleted("2"))
+	assert.Equal(t, wait.ErrWaitTimeout, err)
+
+	// captain failed to uninstall it
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		failed := stale.DeepCopy()
+		failed.ResourceVersion = "3"
+		_, _ = cli.AppV1alpha1().HelmRequests("default").Update(failed)
+	}()
+	hr, err = p.WaitHelmRequest("foo", WaitOptions{Timeout: time.Second}, HelmRequestDeleted("2"))
+	assert.Equal(t, ErrWaitFailed, err)
+	assert.Equal(t, v1alpha1.HelmRequestFailed, hr.Status.Phase)
+}
+
+func TestWaitReleasesDeleted(t *testing.T) {
+	newRelease := func(name string, version int) *v1alpha1.Release {
+		var rel v1alpha1.Release
+		rel.Name = makeKey(name, version)
+		rel.Namespace = "default"
+		rel.Labels = map[string]string{"name": name}
+		return &rel
+	}
+	cli := fake.NewSimpleClientset(newRelease("foo", 1), newRelease("foo", 2), newRelease("bar", 1))
+	p := &CaptainContext{cli: cli, namespace: "default"}
+
+	err := p.WaitReleasesDeleted("foo", "default", WaitOptions{Timeout: 100 * time.Millisecond})
+	assert.Equal(t, wait.ErrWaitTimeout, err)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		for _, version := range []int{1, 2} {
+			_ = cli.AppV1alpha1().Releases("default").Delete(makeKey("foo", version), &metav1.DeleteOptions{})
+		}
+	}()
+	err = p.WaitReleasesDeleted("foo", "default", WaitOptions{Timeout: time.Second})
+	assert.Nil(t, err)
+}
+
+func TestResyncHelmRequest(t *testing.T) {
+	cli := fake.NewSimpleClientset(newHelmRequest("foo", v1alpha1.HelmRequestSynced))
+	p := &CaptainContext{cli: cli, namespace: "default"}
+
+	hr, err := p.ResyncHelmRequest("foo")
+	assert.Nil(t, err)
+	assert.Equal(t, v1alpha1.HelmRequestPending, hr.Status.Phase)
+}